@@ -0,0 +1,73 @@
+package authz
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// TokenStore maps bearer tokens to the principals they authenticate.
+type TokenStore map[string]*Principal
+
+type principalKey struct{}
+
+// FromContext returns the principal set by Authenticate, or nil for an
+// anonymous request.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticate reads an "Authorization: Bearer <token>" header and, if
+// the token is known, stores its principal in the request context.
+// Unknown or missing tokens leave the request anonymous.
+func Authenticate(tokens TokenStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if ok && strings.EqualFold(scheme, "Bearer") {
+				if p, found := tokens[strings.TrimSpace(token)]; found {
+					r = r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Enforcer applies policies to HTTP handlers and logs every decision.
+// A nil Logger uses the standard logger.
+type Enforcer struct {
+	Logger *log.Logger
+}
+
+// Require only lets a request through when policy allows its principal.
+// Denied anonymous requests get 401, denied authenticated requests 403.
+func (e *Enforcer) Require(policy *Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := FromContext(r.Context())
+			decision := policy.Evaluate(principal)
+			e.logf("%s %s: %s", r.Method, r.URL.Path, decision)
+
+			switch {
+			case decision.Allowed:
+				next.ServeHTTP(w, r)
+			case principal == nil:
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			default:
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func (e *Enforcer) logf(format string, args ...any) {
+	if e.Logger != nil {
+		e.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,74 @@
+package authz
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequire(t *testing.T) {
+	tokens := TokenStore{
+		"admin-token":  {ID: "ann", Roles: []string{"admin"}},
+		"reader-token": {ID: "bob", Roles: []string{"reader"}},
+	}
+
+	var logs bytes.Buffer
+	enforcer := &Enforcer{Logger: log.New(&logs, "", 0)}
+
+	var reached *Principal
+	h := Authenticate(tokens)(enforcer.Require(MustParse("role:admin"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = FromContext(r.Context())
+			w.Write([]byte("secret"))
+		}),
+	))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		log           string
+	}{
+		{"no header", "", http.StatusUnauthorized, "anonymous denied"},
+		{"unknown token", "Bearer nope", http.StatusUnauthorized, "anonymous denied"},
+		{"other scheme", "Basic admin-token", http.StatusUnauthorized, "anonymous denied"},
+		{"missing role", "Bearer reader-token", http.StatusForbidden, "bob denied"},
+		{"allowed", "Bearer admin-token", http.StatusOK, "ann allowed"},
+		{"scheme is case-insensitive", "bearer admin-token", http.StatusOK, "ann allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			reached = nil
+
+			req := httptest.NewRequest("GET", "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if (tt.status == http.StatusUnauthorized) != (challenge == "Bearer") {
+				t.Errorf("WWW-Authenticate = %q on a %d", challenge, rec.Code)
+			}
+			if tt.status == http.StatusOK {
+				if reached == nil || reached.ID != "ann" || rec.Body.String() != "secret" {
+					t.Errorf("handler saw %v and wrote %q", reached, rec.Body)
+				}
+			} else if reached != nil {
+				t.Error("denied request reached the handler")
+			}
+			if !strings.Contains(logs.String(), "GET /admin: "+tt.log) {
+				t.Errorf("log = %q, want %q", logs.String(), tt.log)
+			}
+		})
+	}
+}
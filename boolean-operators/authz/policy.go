@@ -0,0 +1,262 @@
+package authz
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Policy is a parsed access rule such as
+//
+//	authenticated and (role:staff or perm:publish)
+//
+// Terms are authenticated, role:NAME and perm:NAME. They can be combined
+// with and, or, not and parentheses, or with &&, || and !. not binds
+// tightest, then and, then or.
+type Policy struct {
+	source string
+	root   node
+}
+
+// Parse compiles a policy expression.
+func Parse(source string) (*Policy, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("authz: unexpected %q at end of policy", p.tokens[p.pos])
+	}
+	return &Policy{source: source, root: root}, nil
+}
+
+// MustParse is like Parse but panics if the policy is invalid. It is
+// intended for policies written in source code.
+func MustParse(source string) *Policy {
+	p, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Policy) String() string {
+	return p.source
+}
+
+// Decision is the outcome of evaluating a policy for a principal.
+// Reason shows the value of every term that was checked.
+type Decision struct {
+	Allowed   bool
+	Policy    string
+	Principal string
+	Reason    string
+}
+
+func (d Decision) String() string {
+	verdict := "denied"
+	if d.Allowed {
+		verdict = "allowed"
+	}
+	return fmt.Sprintf("%s %s by %q: %s", d.Principal, verdict, d.Policy, d.Reason)
+}
+
+// Evaluate checks the policy against pr, which may be nil for an
+// anonymous caller.
+func (p *Policy) Evaluate(pr *Principal) Decision {
+	var reason strings.Builder
+	allowed := p.root.eval(pr, &reason)
+
+	return Decision{
+		Allowed:   allowed,
+		Policy:    p.source,
+		Principal: pr.String(),
+		Reason:    reason.String(),
+	}
+}
+
+type node interface {
+	eval(pr *Principal, reason *strings.Builder) bool
+}
+
+type termNode struct {
+	kind string // "authenticated", "role" or "perm"
+	name string
+}
+
+func (n termNode) eval(pr *Principal, reason *strings.Builder) bool {
+	var result bool
+	switch n.kind {
+	case "authenticated":
+		result = pr != nil
+		fmt.Fprintf(reason, "authenticated=%t", result)
+		return result
+	case "role":
+		result = pr.HasRole(n.name)
+	case "perm":
+		result = pr.HasPermission(n.name)
+	}
+	fmt.Fprintf(reason, "%s:%s=%t", n.kind, n.name, result)
+	return result
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(pr *Principal, reason *strings.Builder) bool {
+	reason.WriteString("not ")
+	return !n.operand.eval(pr, reason)
+}
+
+// binaryNode is an and or or. Like && and || in Go, it stops as soon as
+// the result is known, so the reason only lists terms that were checked.
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n binaryNode) eval(pr *Principal, reason *strings.Builder) bool {
+	reason.WriteString("(")
+	defer reason.WriteString(")")
+
+	left := n.left.eval(pr, reason)
+	if (n.op == "and" && !left) || (n.op == "or" && left) {
+		return left
+	}
+
+	reason.WriteString(" " + n.op + " ")
+	return n.right.eval(pr, reason)
+}
+
+func tokenize(source string) ([]string, error) {
+	var tokens []string
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '!':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, fmt.Errorf("authz: expected %c%c at offset %d", r, r, i)
+			}
+			tokens = append(tokens, string(r)+string(r))
+			i += 2
+		case isTermRune(r):
+			start := i
+			for i < len(runes) && isTermRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("authz: unexpected character %q at offset %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(":_-.", r)
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) accept(words ...string) bool {
+	for _, w := range words {
+		if strings.EqualFold(p.peek(), w) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not", "!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("authz: unexpected end of policy")
+	case tok == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("authz: missing closing parenthesis")
+		}
+		return inner, nil
+	case strings.EqualFold(tok, "authenticated"):
+		p.pos++
+		return termNode{kind: "authenticated"}, nil
+	}
+
+	kind, name, ok := strings.Cut(tok, ":")
+	if !ok || name == "" || (kind != "role" && kind != "perm") {
+		return nil, fmt.Errorf("authz: unknown term %q, want authenticated, role:NAME or perm:NAME", tok)
+	}
+	p.pos++
+	return termNode{kind: kind, name: name}, nil
+}
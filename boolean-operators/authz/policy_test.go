@@ -0,0 +1,125 @@
+package authz
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// Evaluating for an anonymous caller writes the tree's shape into
+	// Reason, which shows how the expression was grouped.
+	tests := []struct {
+		source string
+		reason string
+	}{
+		{"authenticated", "authenticated=false"},
+		{"role:a or role:b and role:c", "(role:a=false or (role:b=false))"},
+		{"role:a || role:b && role:c", "(role:a=false or (role:b=false))"},
+		{"role:a and role:b or role:c", "((role:a=false) or role:c=false)"},
+		{"(role:a or role:b) and role:c", "((role:a=false or role:b=false))"},
+		{"not role:a and role:b", "(not role:a=false and role:b=false)"},
+		{"!role:a && role:b", "(not role:a=false and role:b=false)"},
+		{"not (role:a and role:b)", "not (role:a=false)"},
+		{"not not authenticated", "not not authenticated=false"},
+		{"NOT role:a OR authenticated", "(not role:a=false)"},
+		{"  perm:post.edit   ", "perm:post.edit=false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			p, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Evaluate(nil).Reason; got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "unexpected end of policy"},
+		{"role:", `unknown term "role:"`},
+		{"group:admin", `unknown term "group:admin"`},
+		{"admin", `unknown term "admin"`},
+		{"(role:a", "missing closing parenthesis"},
+		{"(role:a or (role:b)", "missing closing parenthesis"},
+		{"role:a)", `unexpected ")" at end of policy`},
+		{"role:a role:b", `unexpected "role:b" at end of policy`},
+		{"role:a and", "unexpected end of policy"},
+		{"not", "unexpected end of policy"},
+		{"&x", "expected && at offset 0"},
+		{"role:a |", "expected || at offset 7"},
+		{"role:a $ role:b", "unexpected character '$' at offset 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			if err == nil {
+				t.Fatal("Parse succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %q, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMustParsePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParse did not panic on an invalid policy")
+		}
+	}()
+	MustParse("role:")
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := MustParse("authenticated and (role:staff or perm:publish)")
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		allowed   bool
+		reason    string
+	}{
+		{"anonymous", nil, false, "(authenticated=false)"},
+		{"role short-circuits", &Principal{ID: "ann", Roles: []string{"staff"}}, true,
+			"(authenticated=true and (role:staff=true))"},
+		{"permission", &Principal{ID: "bob", Permissions: []string{"publish"}}, true,
+			"(authenticated=true and (role:staff=false or perm:publish=true))"},
+		{"neither", &Principal{ID: "cat", Roles: []string{"reader"}}, false,
+			"(authenticated=true and (role:staff=false or perm:publish=false))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := policy.Evaluate(tt.principal)
+
+			if d.Allowed != tt.allowed {
+				t.Errorf("Allowed = %t, want %t", d.Allowed, tt.allowed)
+			}
+			if d.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", d.Reason, tt.reason)
+			}
+			if d.Principal != tt.principal.String() || d.Policy != policy.String() {
+				t.Errorf("Decision = %+v, want principal %s and the policy source", d, tt.principal)
+			}
+		})
+	}
+}
+
+func TestDecisionString(t *testing.T) {
+	d := MustParse("role:admin").Evaluate(&Principal{ID: "ann"})
+
+	want := `ann denied by "role:admin": role:admin=false`
+	if got := d.String(); got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,28 @@
+package authz
+
+import "slices"
+
+// Principal is an authenticated caller and what it is allowed to do.
+type Principal struct {
+	ID          string
+	Roles       []string
+	Permissions []string
+}
+
+// HasRole reports whether p has the named role. A nil Principal has no
+// roles.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether p has the named permission.
+func (p *Principal) HasPermission(perm string) bool {
+	return p != nil && slices.Contains(p.Permissions, perm)
+}
+
+func (p *Principal) String() string {
+	if p == nil {
+		return "anonymous"
+	}
+	return p.ID
+}
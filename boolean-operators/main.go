@@ -1,8 +1,18 @@
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"boolean-operators/authz"
+)
 
 func main() {
+	serve := flag.Bool("serve", false, "start an HTTP server that enforces the policy")
+	flag.Parse()
+
 	// Challenge
 	//Create a program with three variables:
 	//
@@ -24,4 +34,35 @@ func main() {
 	} else {
 		fmt.Println("Action denied")
 	}
+
+	// The same rule as a policy that can be loaded from a string.
+	policy := authz.MustParse("authenticated and (role:staff or perm:action)")
+
+	principals := []*authz.Principal{
+		nil,
+		{ID: "guest"},
+		{ID: "editor", Permissions: []string{"action"}},
+		{ID: "gary", Roles: []string{"staff"}},
+	}
+	for _, p := range principals {
+		fmt.Println(policy.Evaluate(p))
+	}
+
+	if !*serve {
+		return
+	}
+
+	tokens := authz.TokenStore{
+		"guest-token":  principals[1],
+		"editor-token": principals[2],
+		"staff-token":  principals[3],
+	}
+	enforcer := &authz.Enforcer{}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /action", enforcer.Require(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Action allowed for", authz.FromContext(r.Context()))
+	})))
+
+	log.Fatal(http.ListenAndServe(":8080", authz.Authenticate(tokens)(mux)))
 }
@@ -0,0 +1,320 @@
+// Package eventbus delivers in-process events to subscribers over
+// channels.
+//
+// Topics are dot-separated names such as "orders.created". A
+// subscription pattern can use "*" to match exactly one segment and "#"
+// as its last segment to match any number of remaining segments, so
+// "orders.*" matches "orders.created" and "orders.#" also matches
+// "orders.item.added".
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by Publish after the bus has been closed.
+var ErrClosed = errors.New("eventbus: closed")
+
+// Event is a message published on a topic.
+type Event struct {
+	Topic   string
+	Payload any
+	Time    time.Time
+}
+
+// OverflowPolicy decides what happens when a subscriber's buffer is
+// full.
+type OverflowPolicy int
+
+const (
+	// Block makes the publisher wait until the subscriber has room.
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest
+	// DropNewest discards the event being published.
+	DropNewest
+)
+
+// Stats counts events for the whole bus or for one subscription. An
+// event is delivered once it is in a subscriber's buffer, even if
+// DropOldest later discards it.
+type Stats struct {
+	Published   uint64
+	Delivered   uint64
+	Dropped     uint64
+	Subscribers int
+}
+
+// Subscription receives the events whose topic matches its pattern.
+type Subscription struct {
+	bus     *Bus
+	id      uint64
+	pattern []string
+	policy  OverflowPolicy
+	ch      chan Event
+
+	// sendMu serialises drop policies so that removing the oldest event
+	// and adding the new one happen together.
+	sendMu sync.Mutex
+
+	// closeMu is held for reading by every delivery and for writing
+	// while ch is closed, so nothing sends on a closed channel.
+	closeMu sync.RWMutex
+
+	done      chan struct{}
+	stopOnce  sync.Once
+	closeOnce sync.Once
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// C returns the channel events are delivered on. It is closed after
+// Unsubscribe.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Unsubscribe stops delivery and closes the subscription's channel. It
+// is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.closeOnce.Do(func() {
+		// Wake any publisher blocked on this subscriber first, so it
+		// releases closeMu.
+		s.stop()
+
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s.id)
+		s.bus.mu.Unlock()
+
+		s.closeMu.Lock()
+		close(s.ch)
+		s.closeMu.Unlock()
+	})
+}
+
+// stop closes done, which ends every delivery to s.
+func (s *Subscription) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// Stats returns the number of events delivered to and dropped for this
+// subscription.
+func (s *Subscription) Stats() Stats {
+	return Stats{Delivered: s.delivered.Load(), Dropped: s.dropped.Load()}
+}
+
+func (s *Subscription) addDelivered() {
+	s.delivered.Add(1)
+	s.bus.delivered.Add(1)
+}
+
+func (s *Subscription) addDropped() {
+	s.dropped.Add(1)
+	s.bus.dropped.Add(1)
+}
+
+func (s *Subscription) deliver(ctx context.Context, e Event) error {
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+
+	// done is closed before ch, so checking it here under closeMu
+	// keeps sends off a closed channel.
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	if s.policy == Block {
+		// Try the send on its own first. In one select with a ctx that
+		// is already done, a subscriber with room would only get the
+		// event half the time.
+		select {
+		case s.ch <- e:
+			s.addDelivered()
+			return nil
+		default:
+		}
+
+		select {
+		case s.ch <- e:
+			s.addDelivered()
+			return nil
+		case <-s.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	select {
+	case s.ch <- e:
+		s.addDelivered()
+		return nil
+	default:
+	}
+
+	if s.policy == DropOldest {
+		select {
+		case <-s.ch:
+			s.addDropped()
+		default:
+		}
+		select {
+		case s.ch <- e:
+			s.addDelivered()
+			return nil
+		default:
+		}
+	}
+
+	s.addDropped()
+	return nil
+}
+
+// Bus routes published events to matching subscriptions. It is safe
+// for concurrent use by many publishers and subscribers.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]*Subscription
+	nextID uint64
+	closed bool
+
+	published atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// New returns an empty bus.
+func New() *Bus {
+	return &Bus{subs: make(map[uint64]*Subscription)}
+}
+
+// Subscribe registers interest in topics matching pattern. buffer is
+// the size of the subscription's channel.
+func (b *Bus) Subscribe(pattern string, buffer int, policy OverflowPolicy) *Subscription {
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	s := &Subscription{
+		bus:     b,
+		id:      b.nextID,
+		pattern: strings.Split(pattern, "."),
+		policy:  policy,
+		ch:      make(chan Event, buffer),
+		done:    make(chan struct{}),
+	}
+
+	if b.closed {
+		// Hand back a subscription that is already finished, so range
+		// loops over C end straight away.
+		s.closeOnce.Do(func() {
+			s.stop()
+			close(s.ch)
+		})
+		return s
+	}
+	b.subs[s.id] = s
+	return s
+}
+
+// Publish sends an event to every matching subscription. With the Block
+// policy it waits for slow subscribers until ctx is done. A subscriber
+// that times out does not stop delivery to the others: every matching
+// subscription is tried, and the failures are returned joined with
+// errors.Join.
+func (b *Bus) Publish(ctx context.Context, topic string, payload any) error {
+	e := Event{Topic: topic, Payload: payload, Time: time.Now()}
+	segments := strings.Split(topic, ".")
+
+	// Take the matching subscriptions and let go of the lock before
+	// delivering, so a blocked delivery does not hold up Subscribe,
+	// Close or other publishers.
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	b.published.Add(1)
+
+	var matched []*Subscription
+	for _, s := range b.subs {
+		if match(s.pattern, segments) {
+			matched = append(matched, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range matched {
+		err := s.deliver(ctx, e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("eventbus: deliver to %q: %w", strings.Join(s.pattern, "."), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns totals for the life of the bus, including
+// subscriptions that have since been removed.
+func (b *Bus) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return Stats{
+		Published:   b.published.Load(),
+		Delivered:   b.delivered.Load(),
+		Dropped:     b.dropped.Load(),
+		Subscribers: len(b.subs),
+	}
+}
+
+// Close unsubscribes everyone. Later calls to Publish return ErrClosed.
+func (b *Bus) Close() {
+	// Wake publishers blocked on a subscriber before waiting for the
+	// write lock.
+	b.mu.RLock()
+	for _, s := range b.subs {
+		s.stop()
+	}
+	b.mu.RUnlock()
+
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+func match(pattern, topic []string) bool {
+	for i, p := range pattern {
+		if p == "#" && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(topic) || (p != "*" && p != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
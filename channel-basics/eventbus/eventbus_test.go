@@ -0,0 +1,351 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// within fails the test if fn does not return in time, which is how a
+// deadlock shows up.
+func within(t *testing.T, what string, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s did not return", what)
+	}
+}
+
+// drain returns the payloads buffered in s without waiting.
+func drain(s *Subscription) []any {
+	var got []any
+	for {
+		select {
+		case e, ok := <-s.C():
+			if !ok {
+				return got
+			}
+			got = append(got, e.Payload)
+		default:
+			return got
+		}
+	}
+}
+
+func publishAll(t *testing.T, b *Bus, topic string, payloads ...any) {
+	t.Helper()
+	for _, p := range payloads {
+		err := b.Publish(context.Background(), topic, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.shipped", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.item.added", false},
+		{"*.created", "users.created", true},
+		{"*.*", "a.b", true},
+		{"orders.#", "orders.created", true},
+		{"orders.#", "orders.item.added", true},
+		{"orders.#", "orders", true},
+		{"orders.#", "users.created", false},
+		{"#", "anything.at.all", true},
+		{"orders.*.added", "orders.item.added", true},
+		{"orders.*.added", "orders.item.removed", false},
+	}
+
+	for _, tt := range tests {
+		got := match(strings.Split(tt.pattern, "."), strings.Split(tt.topic, "."))
+		if got != tt.want {
+			t.Errorf("match(%q, %q) = %t, want %t", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestPublishRoutesByPattern(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	orders := b.Subscribe("orders.*", 10, Block)
+	all := b.Subscribe("#", 10, Block)
+	users := b.Subscribe("users.#", 10, Block)
+
+	publishAll(t, b, "orders.created", "o1")
+	publishAll(t, b, "orders.item.added", "i1")
+	publishAll(t, b, "users.signed.up", "u1")
+
+	check := func(name string, s *Subscription, want ...any) {
+		t.Helper()
+		got := drain(s)
+		if len(got) != len(want) {
+			t.Errorf("%s got %v, want %v", name, got, want)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s got %v, want %v", name, got, want)
+			}
+		}
+	}
+	check("orders.*", orders, "o1")
+	check("#", all, "o1", "i1", "u1")
+	check("users.#", users, "u1")
+}
+
+func TestBlockWaitsForReader(t *testing.T) {
+	b := New()
+	defer b.Close()
+	s := b.Subscribe("t", 0, Block)
+
+	var got []any
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 3 {
+			got = append(got, (<-s.C()).Payload)
+		}
+	})
+	publishAll(t, b, "t", 1, 2, 3)
+	wg.Wait()
+
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Errorf("got %v, want [1 2 3] in order", got)
+	}
+	if st := s.Stats(); st.Delivered != 3 || st.Dropped != 0 {
+		t.Errorf("Stats = %+v, want 3 delivered", st)
+	}
+}
+
+func TestBlockHonoursContext(t *testing.T) {
+	b := New()
+	defer b.Close()
+	b.Subscribe("t", 0, Block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Publish(ctx, "t", "x")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBlockTimeoutStillDeliversToOthers(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var ready []*Subscription
+	for range 5 {
+		b.Subscribe("t", 0, Block)
+		ready = append(ready, b.Subscribe("t", 1, Block))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Publish(ctx, "t", "x")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish = %v, want context.DeadlineExceeded", err)
+	}
+	if n := len(strings.Split(err.Error(), "\n")); n != 5 {
+		t.Errorf("Publish returned %d errors, want 5: %v", n, err)
+	}
+	for i, s := range ready {
+		if got := drain(s); len(got) != 1 {
+			t.Errorf("subscriber %d got %v, want the event", i, got)
+		}
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   []any
+	}{
+		{DropOldest, []any{4, 5}},
+		{DropNewest, []any{1, 2}},
+	}
+
+	for _, tt := range tests {
+		b := New()
+		s := b.Subscribe("t", 2, tt.policy)
+
+		// Nobody reads, so only two of the five fit.
+		publishAll(t, b, "t", 1, 2, 3, 4, 5)
+
+		got := drain(s)
+		if len(got) != 2 || got[0] != tt.want[0] || got[1] != tt.want[1] {
+			t.Errorf("policy %d kept %v, want %v", tt.policy, got, tt.want)
+		}
+
+		// DropOldest delivers every event and drops three from the
+		// buffer later; DropNewest never delivers the last three.
+		st := s.Stats()
+		wantDelivered := uint64(5)
+		if tt.policy == DropNewest {
+			wantDelivered = 2
+		}
+		if st.Delivered != wantDelivered || st.Dropped != 3 {
+			t.Errorf("policy %d Stats = %+v, want %d delivered and 3 dropped", tt.policy, st, wantDelivered)
+		}
+		b.Close()
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	s := b.Subscribe("t", 5, DropNewest)
+	other := b.Subscribe("t", 5, DropNewest)
+	publishAll(t, b, "t", 1)
+
+	s.Unsubscribe()
+	s.Unsubscribe() // safe to repeat
+
+	// Buffered events are still received, then the channel is closed.
+	if e, ok := <-s.C(); !ok || e.Payload != 1 {
+		t.Errorf("first receive = %v, %t, want the buffered event", e.Payload, ok)
+	}
+	if _, ok := <-s.C(); ok {
+		t.Error("C still open after Unsubscribe")
+	}
+
+	publishAll(t, b, "t", 2)
+	if got := b.Stats().Subscribers; got != 1 {
+		t.Errorf("Subscribers = %d, want 1", got)
+	}
+	if got := drain(other); len(got) != 2 {
+		t.Errorf("remaining subscriber got %v, want both events", got)
+	}
+}
+
+func TestUnsubscribeWakesBlockedPublisher(t *testing.T) {
+	b := New()
+	defer b.Close()
+	s := b.Subscribe("t", 0, Block)
+
+	published := make(chan error)
+	go func() { published <- b.Publish(context.Background(), "t", "x") }()
+	waitForPublish(t, b, 1)
+
+	within(t, "Unsubscribe", s.Unsubscribe)
+	if err := <-published; err != nil {
+		t.Errorf("Publish = %v, want nil", err)
+	}
+}
+
+// waitForPublish waits until n events have been published, so the last
+// publisher has reached delivery.
+func waitForPublish(t *testing.T, b *Bus, n uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Stats().Published < n {
+		if time.Now().After(deadline) {
+			t.Fatal("publish never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Give the publisher a moment to block on the full channel.
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestBlockedPublisherDoesNotHoldBus(t *testing.T) {
+	b := New()
+	b.Subscribe("slow", 0, Block) // never read
+
+	published := make(chan error)
+	go func() { published <- b.Publish(context.Background(), "slow", "x") }()
+	waitForPublish(t, b, 1)
+
+	// Other topics, new subscribers and Stats keep working.
+	var fast *Subscription
+	within(t, "Subscribe", func() { fast = b.Subscribe("fast", 1, DropNewest) })
+	within(t, "Publish to another topic", func() { publishAll(t, b, "fast", "y") })
+	if got := drain(fast); len(got) != 1 {
+		t.Errorf("fast subscriber got %v", got)
+	}
+
+	within(t, "Close", b.Close)
+	if err := <-published; err != nil {
+		t.Errorf("blocked Publish = %v, want nil after Close", err)
+	}
+
+	if err := b.Publish(context.Background(), "slow", "z"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish after Close = %v, want ErrClosed", err)
+	}
+	late := b.Subscribe("slow", 1, Block)
+	if _, ok := <-late.C(); ok {
+		t.Error("subscription made after Close is open")
+	}
+}
+
+func TestBusStats(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	keep := b.Subscribe("t", 1, DropNewest)
+	gone := b.Subscribe("t", 10, DropNewest)
+
+	publishAll(t, b, "t", 1, 2)
+	gone.Unsubscribe()
+	publishAll(t, b, "other", 3)
+
+	want := Stats{Published: 3, Delivered: 3, Dropped: 1, Subscribers: 1}
+	if got := b.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	if got := keep.Stats(); got.Delivered != 1 || got.Dropped != 1 {
+		t.Errorf("subscription Stats = %+v, want 1 delivered and 1 dropped", got)
+	}
+}
+
+func TestConcurrentPublishAndUnsubscribe(t *testing.T) {
+	b := New()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for i := range 500 {
+				b.Publish(context.Background(), "t", i)
+			}
+		})
+	}
+	for _, policy := range []OverflowPolicy{Block, DropOldest, DropNewest} {
+		for range 3 {
+			s := b.Subscribe("#", 1, policy)
+			wg.Go(func() {
+				n := 0
+				for range s.C() {
+					n++
+					if n == 50 {
+						s.Unsubscribe()
+					}
+				}
+			})
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	within(t, "Close", b.Close)
+	wg.Wait()
+}
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"channel-basics/eventbus"
+	"channel-basics/pipeline"
+)
 
 func main() {
 	// Create a channel for integers
@@ -16,4 +22,60 @@ func main() {
 	value := <-numbers
 	fmt.Println("Received:", value)
 
+	// Chain stages together: each one is a goroutine connected to the
+	// next by a channel.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := pipeline.Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	evens := pipeline.Filter(ctx, source, func(n int) bool { return n%2 == 0 })
+
+	// Two workers square the numbers, then their results are merged.
+	workers := pipeline.FanOut(ctx, evens, 2)
+	squared := make([]<-chan int, len(workers))
+	for i, w := range workers {
+		squared[i] = pipeline.Map(ctx, w, func(n int) int { return n * n })
+	}
+
+	logged, results := pipeline.Tee(ctx, pipeline.Merge(ctx, squared...))
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for n := range logged {
+			fmt.Println("Squared:", n)
+		}
+	}()
+
+	for batch := range pipeline.Batch(ctx, results, 2) {
+		fmt.Println("Batch:", batch)
+	}
+
+	// Wait for the other branch of the Tee to finish printing before
+	// moving on.
+	<-printed
+
+	// An event bus delivers each published event to every subscriber
+	// whose pattern matches the topic.
+	bus := eventbus.New()
+	defer bus.Close()
+
+	orders := bus.Subscribe("orders.*", 10, eventbus.Block)
+	everything := bus.Subscribe("#", 1, eventbus.DropOldest)
+
+	bus.Publish(ctx, "orders.created", "order 1")
+	bus.Publish(ctx, "orders.shipped", "order 1")
+	bus.Publish(ctx, "users.signed_up", "gary")
+
+	orders.Unsubscribe()
+	for e := range orders.C() {
+		fmt.Println("Order event:", e.Topic, e.Payload)
+	}
+
+	// The second subscriber only had room for one event, so the older
+	// ones were dropped.
+	e := <-everything.C()
+	fmt.Println("Latest event:", e.Topic, e.Payload)
+
+	stats := bus.Stats()
+	fmt.Printf("Published: %d, delivered: %d, dropped: %d\n", stats.Published, stats.Delivered, stats.Dropped)
 }
@@ -0,0 +1,203 @@
+// Package pipeline provides typed stages that connect goroutines with
+// channels.
+//
+// Every stage starts its own goroutine, closes its output channel when
+// its input is exhausted, and stops early when ctx is cancelled, so a
+// cancelled pipeline never leaves a goroutine blocked on a send.
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// send delivers v on out unless ctx is cancelled first. It reports
+// whether the value was sent.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recv receives from in unless ctx is cancelled first. It reports false
+// when in is closed or ctx is done, so a stage also stops when its
+// input is an external channel that is never closed.
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Generate emits values in order, then closes the channel.
+func Generate[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map emits fn(v) for every v received from in.
+func Map[T, R any](ctx context.Context, in <-chan T, fn func(T) R) <-chan R {
+	out := make(chan R)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			if !send(ctx, out, fn(v)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Filter emits only the values for which keep returns true.
+func Filter[T any](ctx context.Context, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			if keep(v) && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Batch groups values into slices of up to size elements. The last
+// batch may be smaller.
+func Batch[T any](ctx context.Context, in <-chan T, size int) <-chan []T {
+	if size < 1 {
+		size = 1
+	}
+
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+
+		batch := make([]T, 0, size)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				break
+			}
+			batch = append(batch, v)
+			if len(batch) == size {
+				if !send(ctx, out, batch) {
+					return
+				}
+				batch = make([]T, 0, size)
+			}
+		}
+		if len(batch) > 0 && ctx.Err() == nil {
+			send(ctx, out, batch)
+		}
+	}()
+	return out
+}
+
+// FanOut spreads the values from in across n channels. Each value goes
+// to exactly one of them, whichever is ready first, so n consumers can
+// share the work.
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n < 1 {
+		n = 1
+	}
+
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// Merge combines several channels into one. The output is closed once
+// every input has been closed.
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		go func() {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee copies every value from in to both outputs. Each value must be
+// received from both before the next one is read, so the slower reader
+// sets the pace.
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	out1 := make(chan T)
+	out2 := make(chan T)
+
+	go func() {
+		defer close(out1)
+		defer close(out2)
+
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			// A nil channel blocks forever in a select, so setting a
+			// channel to nil once it has the value stops a second send.
+			a, b := out1, out2
+			for a != nil || b != nil {
+				select {
+				case a <- v:
+					a = nil
+				case b <- v:
+					b = nil
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
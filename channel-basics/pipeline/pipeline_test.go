@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// waitForGoroutines fails the test unless the goroutine count drops back
+// to baseline. Stages exit asynchronously after cancel, so it polls.
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("goroutines: %d, baseline %d\n%s", runtime.NumGoroutine(), baseline, buf)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStagesStopOnCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		// build starts the stage and returns the channels to read from.
+		build func(ctx context.Context, in <-chan int) []<-chan int
+	}{
+		{"Generate", func(ctx context.Context, _ <-chan int) []<-chan int {
+			return []<-chan int{Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)}
+		}},
+		{"Map", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Map(ctx, in, func(n int) int { return n * 2 })}
+		}},
+		{"Filter", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Filter(ctx, in, func(n int) bool { return n%2 == 0 })}
+		}},
+		{"Batch", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Map(ctx, Batch(ctx, in, 2), func(b []int) int { return len(b) })}
+		}},
+		{"FanOut", func(ctx context.Context, in <-chan int) []<-chan int {
+			return FanOut(ctx, in, 3)
+		}},
+		{"Merge", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Merge(ctx, in, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10))}
+		}},
+		{"Tee", func(ctx context.Context, in <-chan int) []<-chan int {
+			a, b := Tee(ctx, in)
+			return []<-chan int{a, b}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// The source keeps producing until cancelled and never
+			// closes, so every stage is mid-stream when ctx ends.
+			src := make(chan int)
+			go func() {
+				for i := 0; ; i++ {
+					select {
+					case src <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+
+			outs := tt.build(ctx, src)
+
+			// Read one value from the first output, then walk away
+			// with the others still blocked on a send.
+			if _, ok := <-outs[0]; !ok {
+				t.Fatal("output closed before any value was sent")
+			}
+			cancel()
+
+			waitForGoroutines(t, baseline)
+			for i, out := range outs {
+				select {
+				case _, ok := <-out:
+					if ok {
+						t.Errorf("output %d sent a value after cancel", i)
+					}
+				default:
+					t.Errorf("output %d still open after cancel", i)
+				}
+			}
+		})
+	}
+}
+
+func TestStagesDeliverEverything(t *testing.T) {
+	ctx := context.Background()
+
+	squares := Map(ctx, Generate(ctx, 1, 2, 3, 4, 5), func(n int) int { return n * n })
+	var got []int
+	for v := range Filter(ctx, squares, func(n int) bool { return n%2 == 1 }) {
+		got = append(got, v)
+	}
+	if want := []int{1, 9, 25}; !slices.Equal(got, want) {
+		t.Errorf("Generate/Map/Filter = %v, want %v", got, want)
+	}
+
+	var batches [][]int
+	for b := range Batch(ctx, Generate(ctx, 1, 2, 3, 4, 5), 2) {
+		batches = append(batches, b)
+	}
+	if len(batches) != 3 || len(batches[2]) != 1 {
+		t.Errorf("Batch = %v, want three batches with a short last one", batches)
+	}
+
+	var merged []int
+	for v := range Merge(ctx, FanOut(ctx, Generate(ctx, 1, 2, 3, 4, 5, 6), 3)...) {
+		merged = append(merged, v)
+	}
+	slices.Sort(merged)
+	if want := []int{1, 2, 3, 4, 5, 6}; !slices.Equal(merged, want) {
+		t.Errorf("FanOut/Merge = %v, want %v", merged, want)
+	}
+
+	a, b := Tee(ctx, Generate(ctx, 1, 2, 3))
+	var fromA, fromB []int
+	for a != nil || b != nil {
+		select {
+		case v, ok := <-a:
+			if !ok {
+				a = nil
+				continue
+			}
+			fromA = append(fromA, v)
+		case v, ok := <-b:
+			if !ok {
+				b = nil
+				continue
+			}
+			fromB = append(fromB, v)
+		}
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(fromA, want) || !slices.Equal(fromB, want) {
+		t.Errorf("Tee = %v and %v, want %v twice", fromA, fromB, want)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// assets serves files from an fs.FS. Every file is also available under
+// a fingerprinted name, such as app.3f2a1b9c.css, that changes whenever
+// the content does, so those URLs can be cached forever.
+type assets struct {
+	fsys        fs.FS
+	fingerprint map[string]string // app.css -> app.3f2a1b9c.css
+	original    map[string]string // app.3f2a1b9c.css -> app.css
+	etags       map[string]string // app.css -> "3f2a1b9c..."
+}
+
+func newAssets(fsys fs.FS) (*assets, error) {
+	a := &assets{
+		fsys:        fsys,
+		fingerprint: make(map[string]string),
+		original:    make(map[string]string),
+		etags:       make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hash[:8] + ext
+
+		a.fingerprint[name] = hashed
+		a.original[hashed] = name
+		a.etags[name] = `"` + hash + `"`
+		return nil
+	})
+	return a, err
+}
+
+// path returns the URL for a file using its fingerprinted name.
+func (a *assets) path(name string) string {
+	if hashed, ok := a.fingerprint[name]; ok {
+		return "/static/" + hashed
+	}
+	return "/static/" + name
+}
+
+// serveStatic serves a single file. Fingerprinted names get a year-long
+// immutable cache; plain names must be revalidated on every use.
+// Directories are never listed.
+func (a *assets) serveStatic(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+
+	if original, ok := a.original[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		a.serveFile(w, r, original)
+		return
+	}
+	if _, ok := a.etags[name]; ok {
+		w.Header().Set("Cache-Control", "no-cache")
+		a.serveFile(w, r, name)
+		return
+	}
+
+	notFound(w, r)
+}
+
+// serveApp serves a single-page app. Requests for real files get the
+// file, and every other path gets index.html so the app can route it.
+func (a *assets) serveApp(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+	if _, ok := a.etags[name]; !ok {
+		name = "index.html"
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	a.serveFile(w, r, name)
+}
+
+// serveFile writes name with http.ServeContent, which takes care of
+// Range, If-None-Match and HEAD requests.
+func (a *assets) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", a.etags[name])
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestAssets(t *testing.T) (*assets, http.Handler) {
+	t.Helper()
+
+	a, err := newAssets(fstest.MapFS{
+		"index.html":  {Data: []byte("<h1>app</h1>")},
+		"app.css":     {Data: []byte("body { color: red }")},
+		"img/logo.js": {Data: []byte("draw()")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /static/{path...}", a.serveStatic)
+	mux.HandleFunc("GET /app/{path...}", a.serveApp)
+	return a, mux
+}
+
+func TestAssets(t *testing.T) {
+	a, h := newTestAssets(t)
+
+	tests := []struct {
+		name         string
+		target       string
+		status       int
+		cacheControl string
+		body         string
+	}{
+		{"fingerprinted", a.path("app.css"), http.StatusOK, "public, max-age=31536000, immutable", "body { color: red }"},
+		{"plain name", "/static/app.css", http.StatusOK, "no-cache", "body { color: red }"},
+		{"nested file", "/static/img/logo.js", http.StatusOK, "no-cache", "draw()"},
+		{"stale fingerprint", "/static/app.00000000.css", http.StatusNotFound, "", ""},
+		{"no listing", "/static/", http.StatusNotFound, "", ""},
+		{"no directory listing", "/static/img/", http.StatusNotFound, "", ""},
+		{"missing file", "/static/missing.css", http.StatusNotFound, "", ""},
+		{"app route", "/app/some/route", http.StatusOK, "no-cache", "<h1>app</h1>"},
+		{"app root", "/app/", http.StatusOK, "no-cache", "<h1>app</h1>"},
+		{"app real file", "/app/app.css", http.StatusOK, "no-cache", "body { color: red }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("GET %s = %d, want %d", tt.target, rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestAssetsFingerprintChangesWithContent(t *testing.T) {
+	a, _ := newTestAssets(t)
+	b, err := newAssets(fstest.MapFS{"app.css": {Data: []byte("body { color: blue }")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.path("app.css") == b.path("app.css") {
+		t.Errorf("both versions map to %s", a.path("app.css"))
+	}
+	if got := a.path("unknown.css"); got != "/static/unknown.css" {
+		t.Errorf("path(unknown.css) = %s, want the plain name", got)
+	}
+}
+
+func TestAssetsRange(t *testing.T) {
+	_, h := newTestAssets(t)
+
+	req := httptest.NewRequest("GET", "/static/app.css", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", rec.Code)
+	}
+	if got := rec.Body.String(); got != "body" {
+		t.Errorf("body = %q, want %q", got, "body")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 0-3/19" {
+		t.Errorf("Content-Range = %q, want bytes 0-3/19", got)
+	}
+}
+
+func TestAssetsNotModified(t *testing.T) {
+	_, h := newTestAssets(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/static/app.css", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag set")
+	}
+
+	req := httptest.NewRequest("GET", "/static/app.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want 304", rec.Code)
+	}
+}
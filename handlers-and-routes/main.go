@@ -1,22 +1,94 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
 	"net/http"
 )
 
+func notFound(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Page not found", http.StatusNotFound)
+}
+
+// page returns a handler that renders a template with no extra data.
+func page(rend *renderer, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rend.render(w, http.StatusOK, name, newTemplateData(w, r))
+	}
+}
+
+func submitContact(w http.ResponseWriter, r *http.Request) {
+	if !validCSRF(r) {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		return
+	}
+
+	setFlash(w, "Thanks, your message has been sent.")
+	http.Redirect(w, r, "/contact", http.StatusSeeOther)
+}
+
+func registerRoutes(rt *routeTable, static *assets, rend *renderer) error {
+	routes := []struct {
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{"GET /{$}", page(rend, "home.html")},
+		{"GET /contact", page(rend, "contact.html")},
+		{"POST /contact", submitContact},
+		{"GET /help", page(rend, "help.html")},
+		{"GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "User", r.PathValue("id"))
+		}},
+		{"GET /static/{path...}", static.serveStatic},
+		{"GET /app/{path...}", static.serveApp},
+	}
+
+	for _, route := range routes {
+		err := rt.handle(route.pattern, route.handler)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "Home page")
-	})
+	dev := flag.Bool("dev", false, "reload templates from disk on every request")
+	flag.Parse()
+
+	staticFS, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		log.Fatal(err)
+	}
+	static, err := newAssets(staticFS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := templateFS(*dev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rend, err := newRenderer(templates, template.FuncMap{"asset": static.path}, *dev)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rt := newRouteTable(http.DefaultServeMux)
+
+	err = registerRoutes(rt, static, rend)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	http.HandleFunc("/contact", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "Contact us")
-	})
+	for _, route := range rt.routes() {
+		log.Println("Route:", route)
+	}
 
-	http.HandleFunc("/help", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "Help page")
-	})
+	handler := chain(withNotFound(http.DefaultServeMux), requestID, logRequests, recoverPanic)
 
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", handler)
 }
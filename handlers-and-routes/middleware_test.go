@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"missing", "", false},
+		{"valid", "abc-123_DEF.4", true},
+		{"too long", strings.Repeat("a", maxRequestIDLen+1), false},
+		{"longest allowed", strings.Repeat("a", maxRequestIDLen), true},
+		{"spaces", "abc 123", false},
+		{"log injection", "abc\nlevel=error", false},
+		{"markup", "<script>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header["X-Request-Id"] = []string{tt.incoming}
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get("X-Request-ID")
+			if echoed != seen {
+				t.Errorf("echoed %q but context holds %q", echoed, seen)
+			}
+			if tt.keep && seen != tt.incoming {
+				t.Errorf("id = %q, want %q", seen, tt.incoming)
+			}
+			if !tt.keep && (seen == tt.incoming || !validRequestID(seen)) {
+				t.Errorf("id = %q, want a freshly generated one", seen)
+			}
+		})
+	}
+}
+
+// captureLog sends the standard logger to a buffer for the rest of the
+// test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prev, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(prev)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRecoverPanic(t *testing.T) {
+	logs := captureLog(t)
+
+	h := recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := rec.Header().Get("Connection"); got != "close" {
+		t.Errorf("Connection = %q, want close", got)
+	}
+	if !strings.Contains(logs.String(), "panic: boom") {
+		t.Errorf("log = %q, want the panic value", logs.String())
+	}
+}
+
+func TestRecoverPanicRethrowsAbort(t *testing.T) {
+	captureLog(t)
+
+	h := recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestLogRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}, "GET /path 200"},
+		{"explicit status", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		}, "GET /path 418"},
+		{"nothing written", func(w http.ResponseWriter, r *http.Request) {}, "GET /path 200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+
+			rec := httptest.NewRecorder()
+			logRequests(tt.handler).ServeHTTP(rec, httptest.NewRequest("GET", "/path", nil))
+
+			line := logs.String()
+			if !strings.Contains(line, tt.want) {
+				t.Errorf("log = %q, want it to contain %q", line, tt.want)
+			}
+			if want := " " + strconv.Itoa(rec.Body.Len()) + "B\n"; !strings.HasSuffix(line, want) {
+				t.Errorf("log = %q, want byte count %q", line, want)
+			}
+		})
+	}
+}
+
+func TestStatusRecorderFlushes(t *testing.T) {
+	captureLog(t)
+
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("statusRecorder does not implement http.Flusher")
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("ResponseController.Flush: %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if !rec.Flushed {
+		t.Error("flush did not reach the underlying writer")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// templateData is passed to every page template.
+type templateData struct {
+	Flash     string
+	CSRFToken string
+	RequestID string
+	Data      any
+}
+
+// renderer executes page templates. Each page is parsed together with
+// the layouts and partials. In dev mode templates are read from disk on
+// every render so edits show up without a restart.
+type renderer struct {
+	fsys  fs.FS
+	funcs template.FuncMap
+	dev   bool
+	cache map[string]*template.Template
+}
+
+func newRenderer(fsys fs.FS, funcs template.FuncMap, dev bool) (*renderer, error) {
+	rend := &renderer{fsys: fsys, funcs: funcs, dev: dev}
+	if dev {
+		return rend, nil
+	}
+
+	cache, err := rend.parseAll()
+	if err != nil {
+		return nil, err
+	}
+	rend.cache = cache
+	return rend, nil
+}
+
+func (rend *renderer) parseAll() (map[string]*template.Template, error) {
+	pages, err := fs.Glob(rend.fsys, "pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]*template.Template)
+	for _, page := range pages {
+		ts, err := template.New(path.Base(page)).Funcs(rend.funcs).ParseFS(rend.fsys,
+			"layouts/*.html",
+			"partials/*.html",
+			page,
+		)
+		if err != nil {
+			return nil, err
+		}
+		cache[path.Base(page)] = ts
+	}
+	return cache, nil
+}
+
+// render writes page into a buffer first, so a template error becomes a
+// clean 500 instead of a half-written page.
+func (rend *renderer) render(w http.ResponseWriter, status int, page string, data templateData) {
+	cache := rend.cache
+	if rend.dev {
+		var err error
+		cache, err = rend.parseAll()
+		if err != nil {
+			rend.serverError(w, err)
+			return
+		}
+	}
+
+	ts, ok := cache[page]
+	if !ok {
+		rend.serverError(w, fmt.Errorf("template %s does not exist", page))
+		return
+	}
+
+	var buf bytes.Buffer
+	err := ts.ExecuteTemplate(&buf, "base", data)
+	if err != nil {
+		rend.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+func (rend *renderer) serverError(w http.ResponseWriter, err error) {
+	log.Println("Render error:", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// templateFS returns the embedded templates, or the templates folder on
+// disk when dev is true.
+func templateFS(dev bool) (fs.FS, error) {
+	if dev {
+		return os.DirFS("templates"), nil
+	}
+	return fs.Sub(templateFiles, "templates")
+}
+
+// newTemplateData fills in the values every page needs. Reading the
+// flash message also clears it, so it is only shown once.
+func newTemplateData(w http.ResponseWriter, r *http.Request) templateData {
+	data := templateData{
+		CSRFToken: csrfToken(w, r),
+		RequestID: requestIDFrom(r.Context()),
+	}
+
+	cookie, err := r.Cookie("flash")
+	if err == nil {
+		message, err := base64.URLEncoding.DecodeString(cookie.Value)
+		if err == nil {
+			data.Flash = string(message)
+		}
+		http.SetCookie(w, &http.Cookie{Name: "flash", Path: "/", MaxAge: -1})
+	}
+	return data
+}
+
+// setFlash stores a message to show on the next page that is rendered.
+func setFlash(w http.ResponseWriter, message string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "flash",
+		Value:    base64.URLEncoding.EncodeToString([]byte(message)),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// csrfToken returns the token from the csrf_token cookie, creating the
+// cookie if the client does not have one yet.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie("csrf_token")
+	if err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	token := newCSRFToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// newCSRFToken returns 32 random bytes, base64 encoded so the token is
+// safe in a cookie and a form field.
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// validCSRF reports whether the submitted form token matches the cookie.
+func validCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie("csrf_token")
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	form := r.PostFormValue("csrf_token")
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(form)) == 1
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestRenderer(t *testing.T, dev bool) (*renderer, fstest.MapFS) {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		"layouts/base.html":   {Data: []byte(`{{define "base"}}[{{.Flash}}]{{template "content" .}}{{end}}`)},
+		"partials/empty.html": {Data: []byte(`{{define "empty"}}{{end}}`)},
+		"pages/ok.html":       {Data: []byte(`{{define "content"}}ok {{.Data}}{{end}}`)},
+		// Writes some output, then fails on the out-of-range index.
+		"pages/broken.html": {Data: []byte(`{{define "content"}}partial output {{index .Data 5}}{{end}}`)},
+	}
+	rend, err := newRenderer(fsys, nil, dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rend, fsys
+}
+
+func TestRender(t *testing.T) {
+	captureLog(t)
+	rend, _ := newTestRenderer(t, false)
+
+	tests := []struct {
+		name        string
+		page        string
+		data        any
+		status      int
+		contentType string
+		body        string
+	}{
+		{"ok", "ok.html", "there", http.StatusCreated, "text/html; charset=utf-8", "[]ok there"},
+		{"failing template", "broken.html", []int{1}, http.StatusInternalServerError, "text/plain; charset=utf-8", "Internal server error\n"},
+		{"missing page", "missing.html", nil, http.StatusInternalServerError, "text/plain; charset=utf-8", "Internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rend.render(rec, http.StatusCreated, tt.page, templateData{Data: tt.data})
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestRenderDevReloads(t *testing.T) {
+	rend, fsys := newTestRenderer(t, true)
+
+	fsys["pages/ok.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}edited{{end}}`)}
+
+	rec := httptest.NewRecorder()
+	rend.render(rec, http.StatusOK, "ok.html", templateData{})
+	if got := rec.Body.String(); got != "[]edited" {
+		t.Errorf("body = %q, want the edited template", got)
+	}
+}
+
+func TestFlashShownOnce(t *testing.T) {
+	rend, _ := newTestRenderer(t, false)
+	h := page(rend, "ok.html")
+
+	// submitContact sets the flash cookie on the redirect.
+	rec := httptest.NewRecorder()
+	setFlash(rec, "Saved")
+	flash := rec.Result().Cookies()[0]
+
+	req := httptest.NewRequest("GET", "/contact", nil)
+	req.AddCookie(flash)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if !strings.HasPrefix(rec.Body.String(), "[Saved]") {
+		t.Errorf("body = %q, want the flash message", rec.Body)
+	}
+	cleared := false
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "flash" && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Error("flash cookie was not cleared after it was shown")
+	}
+
+	// The browser drops the cookie, so the next page has no message.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/contact", nil))
+	if !strings.HasPrefix(rec.Body.String(), "[]") {
+		t.Errorf("body = %q, want no flash message", rec.Body)
+	}
+}
+
+func TestSubmitContactCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		form   string
+		status int
+	}{
+		{"matching token", "token-123", "token-123", http.StatusSeeOther},
+		{"no cookie", "", "token-123", http.StatusForbidden},
+		{"no form field", "token-123", "", http.StatusForbidden},
+		{"mismatch", "token-123", "token-456", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.form != "" {
+				form.Set("csrf_token", tt.form)
+			}
+			req := httptest.NewRequest("POST", "/contact", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			submitContact(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			setsFlash := strings.Contains(rec.Header().Get("Set-Cookie"), "flash=")
+			if setsFlash != (tt.status == http.StatusSeeOther) {
+				t.Errorf("flash set = %t on a %d response", setsFlash, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenCookie(t *testing.T) {
+	rec := httptest.NewRecorder()
+	token := csrfToken(rec, httptest.NewRequest("GET", "/contact", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token {
+		t.Fatalf("cookies = %v, want csrf_token=%s", cookies, token)
+	}
+
+	// A client that already has a token keeps it.
+	req := httptest.NewRequest("GET", "/contact", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	if got := csrfToken(rec, req); got != token {
+		t.Errorf("token = %q, want the existing %q", got, token)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("existing token was replaced")
+	}
+}
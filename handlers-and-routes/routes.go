@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// routeTable registers handlers on a ServeMux and remembers every
+// pattern so the routes can be listed later.
+type routeTable struct {
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func newRouteTable(mux *http.ServeMux) *routeTable {
+	return &routeTable{mux: mux}
+}
+
+// handle registers a handler for pattern. ServeMux panics when two
+// patterns conflict, so that panic is turned into an error instead.
+func (rt *routeTable) handle(pattern string, handler http.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("register %q: %v", pattern, r)
+		}
+	}()
+
+	rt.mux.HandleFunc(pattern, handler)
+	rt.patterns = append(rt.patterns, pattern)
+	return nil
+}
+
+// routes returns the registered patterns in sorted order.
+func (rt *routeTable) routes() []string {
+	routes := make([]string, len(rt.patterns))
+	copy(routes, rt.patterns)
+	sort.Strings(routes)
+	return routes
+}
+
+// withNotFound serves notFound when no route matches the request path.
+// A catch-all pattern cannot do this: "/" would also win over the 405
+// for a known path with the wrong method, and "GET /" would turn every
+// unknown path into a 405. So the mux answers first, and only its own
+// 404 is replaced.
+func withNotFound(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(&notFoundWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// notFoundWriter swaps a 404 written by the mux for notFound's response
+// and drops the mux's own body.
+type notFoundWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *notFoundWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound {
+		w.replaced = true
+		notFound(w.ResponseWriter, w.r)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notFoundWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
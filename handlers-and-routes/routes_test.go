@@ -0,0 +1,113 @@
+package main
+
+import (
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestMux(t *testing.T) http.Handler {
+	t.Helper()
+
+	staticFS, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	static, err := newAssets(staticFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := templateFS(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rend, err := newRenderer(templates, template.FuncMap{"asset": static.path}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	err = registerRoutes(newRouteTable(mux), static, rend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withNotFound(mux)
+}
+
+func TestHandleReportsConflicts(t *testing.T) {
+	rt := newRouteTable(http.NewServeMux())
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	err := rt.handle("GET /users/{id}", noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither pattern is more specific than the other: /users/1 matches
+	// both, but each also matches paths the other does not.
+	err = rt.handle("GET /{x}/1", noop)
+	if err == nil {
+		t.Fatal("expected a conflict error")
+	}
+	if !strings.Contains(err.Error(), "GET /{x}/1") {
+		t.Errorf("error %q does not name the pattern", err)
+	}
+
+	if got := rt.routes(); len(got) != 1 || got[0] != "GET /users/{id}" {
+		t.Errorf("routes = %v, want only the first pattern", got)
+	}
+}
+
+func TestHandleAllowsMoreSpecificPatterns(t *testing.T) {
+	rt := newRouteTable(http.NewServeMux())
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	for _, pattern := range []string{"GET /users/{id}", "GET /users/new", "/users/{id}"} {
+		err := rt.handle(pattern, noop)
+		if err != nil {
+			t.Errorf("handle(%q): %v", pattern, err)
+		}
+	}
+}
+
+func TestRoutePrecedence(t *testing.T) {
+	mux := newTestMux(t)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{"GET", "/", http.StatusOK, "", ""},
+		{"GET", "/unknown", http.StatusNotFound, "Page not found", ""},
+		{"GET", "/users/42", http.StatusOK, "User 42", ""},
+		{"GET", "/users/42/extra", http.StatusNotFound, "Page not found", ""},
+		{"POST", "/users/42", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+		{"PUT", "/contact", http.StatusMethodNotAllowed, "", "GET, HEAD, POST"},
+		{"HEAD", "/users/42", http.StatusOK, "", ""},
+		{"POST", "/nope", http.StatusNotFound, "Page not found", ""},
+		{"DELETE", "/users", http.StatusNotFound, "Page not found", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.body)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+document.getElementById("route").textContent = "You are viewing " + window.location.pathname;
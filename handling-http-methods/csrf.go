@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const csrfCookieName = "csrf_token"
+
+// csrfToken returns the session's CSRF token and makes sure the browser
+// has it in a cookie. The cookie is readable by scripts so they can copy
+// it into the X-CSRF-Token header.
+func csrfToken(w http.ResponseWriter, r *http.Request, secure bool) string {
+	s := sessionFrom(r.Context())
+
+	token := s.get("csrf")
+	if token == "" {
+		token = randomToken()
+		s.put("csrf", token)
+	}
+
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value != token {
+		http.SetCookie(w, &http.Cookie{
+			Name:     csrfCookieName,
+			Value:    token,
+			Path:     "/",
+			Secure:   secure,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return token
+}
+
+// requireCSRF rejects a request unless the token sent in the
+// X-CSRF-Token header matches both the CSRF cookie and the token stored
+// in the session. A cross-site attacker can make the browser send the
+// cookie but cannot read it to copy it.
+func requireCSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		submitted := r.Header.Get("X-CSRF-Token")
+
+		cookie, err := r.Cookie(csrfCookieName)
+		expected := sessionFrom(r.Context()).get("csrf")
+
+		if err != nil || expected == "" || submitted == "" ||
+			!tokensEqual(submitted, cookie.Value) || !tokensEqual(submitted, expected) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func tokensEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
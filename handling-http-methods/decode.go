@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const maxBodyBytes = 1 << 20
+
+// decodeError is returned by decodeJSON when the client sent a body
+// that cannot be decoded. Status is the HTTP status to respond with.
+type decodeError struct {
+	Status  int
+	Message string
+}
+
+func (e *decodeError) Error() string {
+	return e.Message
+}
+
+// decodeJSON reads a single JSON object from the request body into dst.
+// The body must be application/json, at most maxBodyBytes long, contain
+// only fields known to dst, and have nothing after the object.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return &decodeError{http.StatusUnsupportedMediaType, "Content-Type must be application/json"}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		var maxBytesErr *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxErr):
+			return &decodeError{http.StatusBadRequest, fmt.Sprintf("malformed JSON at position %d", syntaxErr.Offset)}
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return &decodeError{http.StatusBadRequest, "malformed JSON"}
+		case errors.As(err, &typeErr):
+			return &decodeError{http.StatusBadRequest, fmt.Sprintf("invalid value for field %q", typeErr.Field)}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return &decodeError{http.StatusBadRequest, "unknown field " + field}
+		case errors.Is(err, io.EOF):
+			return &decodeError{http.StatusBadRequest, "request body must not be empty"}
+		case errors.As(err, &maxBytesErr):
+			return &decodeError{http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not be larger than %d bytes", maxBytesErr.Limit)}
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return &decodeError{http.StatusBadRequest, "request body must contain a single JSON object"}
+	}
+
+	return nil
+}
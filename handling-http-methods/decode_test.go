@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int // 0 means the body decodes
+		message     string
+	}{
+		{"valid", "application/json", `{"name":"Ada"}`, 0, ""},
+		{"charset parameter", "application/json; charset=utf-8", `{"name":"Ada"}`, 0, ""},
+		{"missing content type", "", `{"name":"Ada"}`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"wrong content type", "text/plain", `{"name":"Ada"}`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"syntax error", "application/json", `{"name":}`, http.StatusBadRequest, "malformed JSON at position 9"},
+		{"truncated", "application/json", `{"name":"Ada"`, http.StatusBadRequest, "malformed JSON"},
+		{"wrong type", "application/json", `{"name":42}`, http.StatusBadRequest, `invalid value for field "name"`},
+		{"unknown field", "application/json", `{"nickname":"Ada"}`, http.StatusBadRequest, `unknown field "nickname"`},
+		{"empty body", "application/json", ``, http.StatusBadRequest, "request body must not be empty"},
+		{"trailing data", "application/json", `{"name":"Ada"} {}`, http.StatusBadRequest, "request body must contain a single JSON object"},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "request body must not be larger than 1048576 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/submit", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var dst submitRequest
+			err := decodeJSON(httptest.NewRecorder(), req, &dst)
+
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("decodeJSON: %v", err)
+				}
+				if dst.Name != "Ada" {
+					t.Errorf("Name = %q, want Ada", dst.Name)
+				}
+				return
+			}
+
+			var de *decodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("err = %v, want a *decodeError", err)
+			}
+			if de.Status != tt.status || de.Message != tt.message {
+				t.Errorf("got %d %q, want %d %q", de.Status, de.Message, tt.status, tt.message)
+			}
+		})
+	}
+}
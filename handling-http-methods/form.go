@@ -0,0 +1,67 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+var formPage = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Submit</title>
+</head>
+<body>
+    <h1>Submit page</h1>
+    {{with .LastName}}<p>Welcome back, {{.}}.</p>{{end}}
+    <form id="submit-form">
+        <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+        <label>Name <input name="name"></label>
+        <label>Email <input name="email" type="email"></label>
+        <label>Message <textarea name="message"></textarea></label>
+        <button type="submit">Send</button>
+    </form>
+    <pre id="result"></pre>
+    <script>
+    document.getElementById("submit-form").addEventListener("submit", async (event) => {
+        event.preventDefault();
+        const form = new FormData(event.target);
+        const response = await fetch("/submit", {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json",
+                "X-CSRF-Token": form.get("csrf_token"),
+            },
+            body: JSON.stringify({
+                name: form.get("name"),
+                email: form.get("email"),
+                message: form.get("message"),
+            }),
+        });
+        document.getElementById("result").textContent = response.status + " " + await response.text();
+    });
+    </script>
+</body>
+</html>
+`))
+
+// showForm renders the submission form with a CSRF token for the
+// current session.
+func showForm(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := struct {
+			CSRFToken string
+			LastName  string
+		}{
+			CSRFToken: csrfToken(w, r, secure),
+			LastName:  sessionFrom(r.Context()).get("last_name"),
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := formPage.Execute(w, data)
+		if err != nil {
+			log.Println("Failed to render form:", err)
+		}
+	}
+}
@@ -1,21 +1,46 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"encoding/hex"
+	"flag"
+	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
 func main() {
-	http.HandleFunc("/submit", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			fmt.Fprintln(w, "Submit page")
-		case http.MethodPost:
-			fmt.Fprintln(w, "Submission received")
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	sessionDir := flag.String("session-dir", "", "directory to store sessions in; sessions are kept in memory if empty")
+	insecure := flag.Bool("insecure-cookies", false, "allow cookies over plain HTTP for local development")
+	flag.Parse()
+
+	secret := newSecret()
+	if key := os.Getenv("SESSION_SECRET"); key != "" {
+		var err error
+		secret, err = hex.DecodeString(key)
+		if err != nil || len(secret) < 32 {
+			log.Fatal("SESSION_SECRET must be at least 32 hex-encoded bytes")
+		}
+	}
+
+	var store sessionStore = newMemorySessionStore()
+	if *sessionDir != "" {
+		fileStore, err := newFileSessionStore(*sessionDir)
+		if err != nil {
+			log.Fatal("Failed to open session directory:", err)
 		}
-	})
+		store = fileStore
+	}
+
+	sessions := newSessionManager(store, secret, 24*time.Hour)
+	sessions.secure = !*insecure
+	go sessions.sweep(context.Background(), time.Hour)
+
+	http.HandleFunc("/submit", methods(map[string]http.HandlerFunc{
+		http.MethodGet:  showForm(sessions.secure),
+		http.MethodPost: requireCSRF(handleSubmit),
+	}))
 
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", sessions.middleware(http.DefaultServeMux))
 }
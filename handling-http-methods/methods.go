@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// methods dispatches a request to the handler registered for its
+// method. HEAD falls back to the GET handler, OPTIONS is answered
+// automatically, and any other method gets a 405 with an Allow header.
+func methods(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	allowed := make([]string, 0, len(handlers)+2)
+	for method := range handlers {
+		allowed = append(allowed, method)
+	}
+	if _, ok := handlers[http.MethodGet]; ok {
+		if _, ok := handlers[http.MethodHead]; !ok {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	if _, ok := handlers[http.MethodOptions]; !ok {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			handler, ok = handlers[http.MethodGet]
+		}
+		if ok {
+			handler(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	h := methods(map[string]http.HandlerFunc{
+		http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", "get")
+			fmt.Fprint(w, "hello")
+		},
+		http.MethodPost: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		},
+	})
+
+	const allow = "GET, HEAD, OPTIONS, POST"
+
+	tests := []struct {
+		method  string
+		status  int
+		allow   string
+		handler string
+	}{
+		{http.MethodGet, http.StatusOK, "", "get"},
+		{http.MethodHead, http.StatusOK, "", "get"},
+		{http.MethodPost, http.StatusCreated, "", ""},
+		{http.MethodOptions, http.StatusNoContent, allow, ""},
+		{http.MethodPut, http.StatusMethodNotAllowed, allow, ""},
+		{http.MethodDelete, http.StatusMethodNotAllowed, allow, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(tt.method, "/submit", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+			if got := rec.Header().Get("X-Handler"); got != tt.handler {
+				t.Errorf("handler = %q, want %q", got, tt.handler)
+			}
+		})
+	}
+}
+
+func TestMethodsWithoutGet(t *testing.T) {
+	h := methods(map[string]http.HandlerFunc{
+		http.MethodPost: func(w http.ResponseWriter, r *http.Request) {},
+	})
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodHead, "/submit", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got, want := rec.Header().Get("Allow"), "OPTIONS, POST"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoSession = errors.New("session not found")
+
+// session is server-side state for one browser. Only its ID travels in
+// the cookie.
+type session struct {
+	ID      string            `json:"id"`
+	Values  map[string]string `json:"values"`
+	Expires time.Time         `json:"expires"`
+
+	mu sync.Mutex
+	// dirty is set when a value changes, so unchanged sessions are not
+	// written back to the store.
+	dirty bool
+	// isNew is set until the session has been saved and its cookie sent.
+	isNew bool
+}
+
+func (s *session) get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Values[key]
+}
+
+func (s *session) put(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Values[key] != value {
+		s.Values[key] = value
+		s.dirty = true
+	}
+}
+
+// takeDirty reports whether the session changed since the last call.
+func (s *session) takeDirty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dirty := s.dirty
+	s.dirty = false
+	return dirty
+}
+
+type sessionStore interface {
+	load(id string) (*session, error)
+	save(s *session) error
+	delete(id string) error
+	// deleteExpired removes every session that expired before now.
+	deleteExpired(now time.Time) error
+}
+
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*session)}
+}
+
+func (m *memorySessionStore) load(id string) (*session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, errNoSession
+	}
+	return s, nil
+}
+
+func (m *memorySessionStore) save(s *session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *memorySessionStore) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) deleteExpired(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if !now.Before(s.Expires) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// fileSessionStore keeps one JSON file per session, so sessions survive
+// a restart.
+type fileSessionStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileSessionStore(dir string) (*fileSessionStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSessionStore{dir: dir}, nil
+}
+
+func (f *fileSessionStore) path(id string) string {
+	return filepath.Join(f.dir, id+".json")
+}
+
+func (f *fileSessionStore) load(id string) (*session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (f *fileSessionStore) save(s *session) error {
+	s.mu.Lock()
+	data, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp := f.path(s.ID) + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path(s.ID))
+}
+
+func (f *fileSessionStore) delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *fileSessionStore) deleteExpired(now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s session
+		err = json.Unmarshal(data, &s)
+		if err != nil || !now.Before(s.Expires) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+type sessionKey struct{}
+
+// sessionFrom returns the session attached to ctx by sessionManager.
+func sessionFrom(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newSecret() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+// sessionManager signs session cookies with HMAC-SHA256. The cookie
+// holds the session ID, its expiry and a signature over both, so a
+// client cannot forge an ID or extend its own session.
+type sessionManager struct {
+	store  sessionStore
+	secret []byte
+	ttl    time.Duration
+	name   string
+	secure bool
+	now    func() time.Time
+}
+
+func newSessionManager(store sessionStore, secret []byte, ttl time.Duration) *sessionManager {
+	return &sessionManager{
+		store:  store,
+		secret: secret,
+		ttl:    ttl,
+		name:   "session",
+		secure: true,
+		now:    time.Now,
+	}
+}
+
+func (m *sessionManager) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *sessionManager) encode(s *session) string {
+	payload := s.ID + "." + strconv.FormatInt(s.Expires.Unix(), 10)
+	return payload + "." + m.sign(payload)
+}
+
+// decode verifies a cookie value and returns the session ID in it. It
+// fails if the signature does not match or the session has expired.
+func (m *sessionManager) decode(value string) (string, error) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return "", errors.New("malformed session cookie")
+	}
+	payload, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(m.sign(payload))) {
+		return "", errors.New("invalid session signature")
+	}
+
+	id, expiresText, _ := strings.Cut(payload, ".")
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil {
+		return "", errors.New("malformed session cookie")
+	}
+	if m.now().Unix() >= expires {
+		return "", errors.New("session expired")
+	}
+	return id, nil
+}
+
+// load returns the session named by the request's cookie. When the
+// cookie is missing, tampered with or expired it returns a new session
+// that is not saved until something is put in it. Expired sessions are
+// deleted from the store.
+func (m *sessionManager) load(r *http.Request) (*session, error) {
+	cookie, err := r.Cookie(m.name)
+	if err == nil {
+		id, err := m.decode(cookie.Value)
+		if err == nil {
+			s, err := m.store.load(id)
+			switch {
+			case err == nil && m.now().Before(s.Expires):
+				return s, nil
+			case err == nil:
+				err = m.store.delete(id)
+				if err != nil {
+					return nil, err
+				}
+			case !errors.Is(err, errNoSession):
+				return nil, err
+			}
+		}
+	}
+
+	return &session{
+		ID:      randomToken(),
+		Values:  make(map[string]string),
+		Expires: m.now().Add(m.ttl),
+		isNew:   true,
+	}, nil
+}
+
+// save writes s to the store if it changed. The first time a new
+// session is saved its cookie is set on w, so it must be called before
+// the response headers are written.
+func (m *sessionManager) save(w http.ResponseWriter, s *session) error {
+	if !s.takeDirty() {
+		return nil
+	}
+
+	err := m.store.save(s)
+	if err != nil {
+		return err
+	}
+
+	if s.isNew {
+		s.isNew = false
+		http.SetCookie(w, &http.Cookie{
+			Name:     m.name,
+			Value:    m.encode(s),
+			Path:     "/",
+			Expires:  s.Expires,
+			Secure:   m.secure,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return nil
+}
+
+// sessionWriter saves the session just before the response headers are
+// sent, which is the last moment a new session's cookie can be set.
+type sessionWriter struct {
+	http.ResponseWriter
+	m           *sessionManager
+	s           *session
+	wroteHeader bool
+}
+
+func (sw *sessionWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		err := sw.m.save(sw.ResponseWriter, sw.s)
+		if err != nil {
+			log.Println("Failed to save session:", err)
+		}
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *sessionWriter) Flush() {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// middleware attaches the session to the request context. The session
+// is only saved, and a new session's cookie only set, when a handler
+// changes it, so requests that never use the session leave nothing
+// behind.
+func (m *sessionManager) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := m.load(r)
+		if err != nil {
+			log.Println("Failed to load session:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		sw := &sessionWriter{ResponseWriter: w, m: m, s: s}
+		ctx := context.WithValue(r.Context(), sessionKey{}, s)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		// Catch changes made after the headers were sent, or by a
+		// handler that wrote nothing.
+		err = m.save(w, s)
+		if err != nil {
+			log.Println("Failed to save session:", err)
+		}
+	})
+}
+
+// sweep deletes expired sessions every interval until ctx is done.
+func (m *sessionManager) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := m.store.deleteExpired(m.now())
+			if err != nil {
+				log.Println("Failed to delete expired sessions:", err)
+			}
+		}
+	}
+}
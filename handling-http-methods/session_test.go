@@ -0,0 +1,255 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingStore counts saves so tests can check when a session is
+// written.
+type countingStore struct {
+	*memorySessionStore
+	saves int
+}
+
+func (c *countingStore) save(s *session) error {
+	c.saves++
+	return c.memorySessionStore.save(s)
+}
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time { return c.t }
+
+func newTestManager() (*sessionManager, *countingStore, *testClock) {
+	store := &countingStore{memorySessionStore: newMemorySessionStore()}
+	clock := &testClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	m := newSessionManager(store, []byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	m.now = clock.now
+	return m, store, clock
+}
+
+// sessionHandler puts ?set= into the session, if given, and writes the
+// current value of "name" to the response.
+func sessionHandler(m *sessionManager) http.Handler {
+	return m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := sessionFrom(r.Context())
+		if v := r.URL.Query().Get("set"); v != "" {
+			s.put("name", v)
+		}
+		w.Write([]byte(s.get("name")))
+	}))
+}
+
+func send(h http.Handler, target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func sessionCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session" {
+			return c
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+func TestSessionCreatedOnlyWhenWritten(t *testing.T) {
+	m, store, _ := newTestManager()
+	h := sessionHandler(m)
+
+	rec := send(h, "/", nil)
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("a request that does not use the session should not get a cookie")
+	}
+	if store.saves != 0 || len(store.sessions) != 0 {
+		t.Errorf("saves = %d, sessions = %d, want none", store.saves, len(store.sessions))
+	}
+
+	cookie := sessionCookie(t, send(h, "/?set=gary", nil))
+	if store.saves != 1 {
+		t.Errorf("saves = %d, want 1", store.saves)
+	}
+
+	rec = send(h, "/", cookie)
+	if rec.Body.String() != "gary" {
+		t.Errorf("body = %q, want the stored value", rec.Body)
+	}
+	if store.saves != 1 {
+		t.Errorf("an unchanged session was saved again: saves = %d", store.saves)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("an existing session should not get a new cookie")
+	}
+
+	send(h, "/?set=ann", cookie)
+	if store.saves != 2 {
+		t.Errorf("a changed session should be saved: saves = %d", store.saves)
+	}
+}
+
+func TestSessionRejectsTamperedCookie(t *testing.T) {
+	m, _, _ := newTestManager()
+	h := sessionHandler(m)
+
+	cookie := sessionCookie(t, send(h, "/?set=gary", nil))
+	id, rest, _ := strings.Cut(cookie.Value, ".")
+
+	tampered := []string{
+		"x" + cookie.Value[1:],
+		id + ".9999999999." + rest[strings.Index(rest, ".")+1:],
+		id,
+		"",
+		cookie.Value + "x",
+	}
+	for _, value := range tampered {
+		rec := send(h, "/", &http.Cookie{Name: "session", Value: value})
+		if rec.Body.String() != "" {
+			t.Errorf("cookie %q loaded the session", value)
+		}
+	}
+
+	other := newSessionManager(newMemorySessionStore(), []byte("another secret, another secret!!"), time.Hour)
+	if _, err := other.decode(cookie.Value); err == nil {
+		t.Error("a cookie signed with another secret was accepted")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	m, store, clock := newTestManager()
+	h := sessionHandler(m)
+
+	cookie := sessionCookie(t, send(h, "/?set=gary", nil))
+
+	clock.t = clock.t.Add(59 * time.Minute)
+	if rec := send(h, "/", cookie); rec.Body.String() != "gary" {
+		t.Fatalf("session should still be valid, body = %q", rec.Body)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if rec := send(h, "/", cookie); rec.Body.String() != "" {
+		t.Errorf("expired cookie loaded the session, body = %q", rec.Body)
+	}
+
+	// The cookie's own expiry is signed, but the stored expiry is
+	// checked too, and an expired stored session is deleted.
+	s := &session{ID: "abc", Values: map[string]string{"name": "old"}, Expires: clock.t.Add(-time.Second)}
+	store.save(s)
+	forged := &http.Cookie{Name: "session", Value: m.encode(&session{ID: "abc", Expires: clock.t.Add(time.Hour)})}
+	if rec := send(h, "/", forged); rec.Body.String() != "" {
+		t.Errorf("expired stored session was loaded, body = %q", rec.Body)
+	}
+	if _, err := store.load("abc"); err != errNoSession {
+		t.Errorf("expired session still stored: %v", err)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fileStore, err := newFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]sessionStore{
+		"memory": newMemorySessionStore(),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, s := range []*session{
+				{ID: "live", Values: map[string]string{}, Expires: now.Add(time.Minute)},
+				{ID: "dead", Values: map[string]string{}, Expires: now.Add(-time.Minute)},
+				{ID: "edge", Values: map[string]string{}, Expires: now},
+			} {
+				if err := store.save(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := store.deleteExpired(now); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := store.load("live"); err != nil {
+				t.Errorf("live session removed: %v", err)
+			}
+			for _, id := range []string{"dead", "edge"} {
+				if _, err := store.load(id); err != errNoSession {
+					t.Errorf("%s session kept: %v", id, err)
+				}
+			}
+		})
+	}
+
+	files, _ := filepath.Glob(filepath.Join(fileStore.dir, "*"))
+	if len(files) != 1 {
+		t.Errorf("files left = %v, want only the live session", files)
+	}
+	if _, err := os.Stat(fileStore.path("live")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	m, _, _ := newTestManager()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /form", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(csrfToken(w, r, true)))
+	})
+	mux.HandleFunc("POST /form", requireCSRF(func(w http.ResponseWriter, r *http.Request) {}))
+	h := m.middleware(mux)
+
+	rec := send(h, "/form", nil)
+	token := rec.Body.String()
+	session := sessionCookie(t, rec)
+	csrf := &http.Cookie{Name: csrfCookieName, Value: token}
+
+	post := func(header string, cookies ...*http.Cookie) int {
+		req := httptest.NewRequest("POST", "/form", nil)
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		cookies []*http.Cookie
+		want    int
+	}{
+		{"valid", token, []*http.Cookie{session, csrf}, http.StatusOK},
+		{"missing header", "", []*http.Cookie{session, csrf}, http.StatusForbidden},
+		{"wrong header", "nope", []*http.Cookie{session, csrf}, http.StatusForbidden},
+		{"missing csrf cookie", token, []*http.Cookie{session}, http.StatusForbidden},
+		{"no session", token, []*http.Cookie{csrf}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := post(tt.header, tt.cookies...); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+type submitRequest struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+}
+
+// validate returns a map of field name to error message. An empty map
+// means the request is valid.
+func (req submitRequest) validate() map[string]string {
+	errs := make(map[string]string)
+
+	if strings.TrimSpace(req.Name) == "" {
+		errs["name"] = "name is required"
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		errs["email"] = "email is required"
+	} else if !strings.Contains(req.Email, "@") {
+		errs["email"] = "email must be a valid email address"
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		errs["message"] = "message is required"
+	} else if utf8.RuneCountInString(req.Message) > 1000 {
+		errs["message"] = "message must be at most 1000 characters"
+	}
+
+	return errs
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println("Failed to encode JSON:", err)
+	}
+}
+
+func handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+
+	err := decodeJSON(w, r, &req)
+	if err != nil {
+		var decodeErr *decodeError
+		if errors.As(err, &decodeErr) {
+			writeJSON(w, decodeErr.Status, map[string]string{"error": decodeErr.Message})
+			return
+		}
+		log.Println("Failed to read request body:", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		return
+	}
+
+	errs := req.validate()
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]map[string]string{"errors": errs})
+		return
+	}
+
+	sessionFrom(r.Context()).put("last_name", req.Name)
+	writeJSON(w, http.StatusCreated, req)
+}
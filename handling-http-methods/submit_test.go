@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postSubmit(t *testing.T, contentType, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	m, _, _ := newTestManager()
+	h := m.middleware(http.HandlerFunc(handleSubmit))
+
+	req := httptest.NewRequest("POST", "/submit", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, rec.Body)
+	}
+	return rec, got
+}
+
+func TestSubmit(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"created", "application/json", `{"name":"Ada","email":"ada@example.com","message":"hi"}`, http.StatusCreated},
+		{"wrong content type", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"malformed", "application/json", `{`, http.StatusBadRequest},
+		{"unknown field", "application/json", `{"age":3}`, http.StatusBadRequest},
+		{"trailing data", "application/json", `{}{}`, http.StatusBadRequest},
+		{"too large", "application/json", `{"message":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		{"invalid fields", "application/json", `{"email":"nope"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body := postSubmit(t, tt.contentType, tt.body)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %v", rec.Code, tt.status, body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			if tt.status >= 400 && tt.status != http.StatusUnprocessableEntity && body["error"] == nil {
+				t.Errorf("body = %v, want an error message", body)
+			}
+		})
+	}
+}
+
+func TestSubmitValidationErrors(t *testing.T) {
+	_, body := postSubmit(t, "application/json", `{"name":" ","email":"nope"}`)
+
+	errs, ok := body["errors"].(map[string]any)
+	if !ok {
+		t.Fatalf("body = %v, want an errors object", body)
+	}
+	want := map[string]string{
+		"name":    "name is required",
+		"email":   "email must be a valid email address",
+		"message": "message is required",
+	}
+	if len(errs) != len(want) {
+		t.Errorf("errors = %v, want %v", errs, want)
+	}
+	for field, msg := range want {
+		if errs[field] != msg {
+			t.Errorf("errors[%q] = %v, want %q", field, errs[field], msg)
+		}
+	}
+}
+
+func TestMessageLengthCountsCharacters(t *testing.T) {
+	tests := []struct {
+		message string
+		valid   bool
+	}{
+		{strings.Repeat("a", 1000), true},
+		{strings.Repeat("a", 1001), false},
+		// 1000 characters but 3000 bytes.
+		{strings.Repeat("日", 1000), true},
+		{strings.Repeat("日", 1001), false},
+	}
+
+	for _, tt := range tests {
+		req := submitRequest{Name: "Ada", Email: "ada@example.com", Message: tt.message}
+		_, invalid := req.validate()["message"]
+		if invalid == tt.valid {
+			t.Errorf("%d bytes, %d characters: valid = %t, want %t",
+				len(tt.message), len([]rune(tt.message)), !invalid, tt.valid)
+		}
+	}
+}
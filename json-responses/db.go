@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// OpenDB opens a SQLite database. SQLite allows one writer at a time,
+// and each connection to ":memory:" is a separate database, so the pool
+// is limited to a single connection.
+func OpenDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate applies every embedded migration that has not been applied
+// yet. Migration files are named NNNN_description.sql and run in order
+// of their version number, each in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+
+		query, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		err = applyMigration(ctx, db, version, string(query))
+		if err != nil {
+			return fmt.Errorf("apply %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func migrationVersion(name string) (int, error) {
+	base := strings.TrimPrefix(name, "migrations/")
+	prefix, _, ok := strings.Cut(base, "_")
+	if !ok {
+		return 0, fmt.Errorf("migration %s: name must start with a version number", name)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("migration %s: %w", name, err)
+	}
+	return version, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int, query string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SeedIfEmpty inserts sample products when the products table has no
+// rows, so running it more than once has no further effect.
+func SeedIfEmpty(ctx context.Context, db *sql.DB) error {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM products`).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	products := []Product{
+		{Name: "Notebook", Price: 12.99},
+		{Name: "Pen", Price: 1.50},
+		{Name: "Backpack", Price: 39.00},
+	}
+
+	store := &sqlProductStore{db: db}
+	for _, p := range products {
+		_, err := store.Create(ctx, p)
+		if err != nil {
+			return fmt.Errorf("seed product %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
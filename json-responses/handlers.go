@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// validator is implemented by request bodies that can check their own
+// fields. An empty map means the value is valid.
+type validator interface {
+	validate() map[string]string
+}
+
+func (p Product) validate() map[string]string {
+	errs := make(map[string]string)
+	if strings.TrimSpace(p.Name) == "" {
+		errs["name"] = "name is required"
+	}
+	if p.Price <= 0 {
+		errs["price"] = "price must be greater than zero"
+	}
+	return errs
+}
+
+func (u User) validate() map[string]string {
+	errs := make(map[string]string)
+	if strings.TrimSpace(u.Name) == "" {
+		errs["name"] = "name is required"
+	}
+	if !strings.Contains(u.Email, "@") {
+		errs["email"] = "email must be a valid email address"
+	}
+	return errs
+}
+
+// resource serves list, get, create, update and delete endpoints for
+// one Store.
+type resource[T validator] struct {
+	name  string
+	store Store[T]
+}
+
+func (res resource[T]) register(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc("GET "+prefix, res.list)
+	mux.HandleFunc("POST "+prefix, res.create)
+	mux.HandleFunc("GET "+prefix+"/{id}", res.get)
+	mux.HandleFunc("PUT "+prefix+"/{id}", res.update)
+	mux.HandleFunc("DELETE "+prefix+"/{id}", res.delete)
+}
+
+func (res resource[T]) list(w http.ResponseWriter, r *http.Request) {
+	records, err := res.store.List(r.Context())
+	if err != nil {
+		res.serverError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, records)
+}
+
+func (res resource[T]) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := res.store.Get(r.Context(), id)
+	if err != nil {
+		res.storeError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, record)
+}
+
+func (res resource[T]) create(w http.ResponseWriter, r *http.Request) {
+	input, ok := res.decode(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := res.store.Create(r.Context(), input)
+	if err != nil {
+		res.storeError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusCreated, record)
+}
+
+func (res resource[T]) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+	input, ok := res.decode(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := res.store.Update(r.Context(), id, input)
+	if err != nil {
+		res.storeError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, record)
+}
+
+func (res resource[T]) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+
+	err := res.store.Delete(r.Context(), id)
+	if err != nil {
+		res.storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (res resource[T]) id(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		writeProblem(w, r, http.StatusNotFound, res.name+" not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// maxBodyBytes caps the size of a request body.
+const maxBodyBytes = 1 << 20
+
+// decode reads and validates a T from the request body, writing a 400,
+// 413 or 422 response if it cannot. Decoder errors are logged rather
+// than sent to the client, which only needs to know the body was wrong.
+func (res resource[T]) decode(w http.ResponseWriter, r *http.Request) (T, bool) {
+	var input T
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&input)
+	if err == nil && !errors.Is(dec.Decode(&struct{}{}), io.EOF) {
+		err = errors.New("trailing data after JSON object")
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, "request body must not be larger than 1MB")
+		return input, false
+	case err != nil:
+		log.Printf("%s %s: decode body: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, r, http.StatusBadRequest, "request body must be a single JSON "+res.name+" object")
+		return input, false
+	}
+
+	errs := input.validate()
+	if len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		return input, false
+	}
+	return input, true
+}
+
+func (res resource[T]) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, res.name+" not found")
+	case errors.Is(err, ErrConflict):
+		writeProblem(w, r, http.StatusConflict, res.name+" already exists")
+	default:
+		res.serverError(w, r, err)
+	}
+}
+
+func (res resource[T]) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	writeProblem(w, r, http.StatusInternalServerError, "")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// do sends a request to h and returns the recorded response.
+func do(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeData unmarshals a {"data": ...} response body into a T.
+func decodeData[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var body envelope[T]
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+	return body.Data
+}
+
+// testProductResource exercises every verb of /products against the
+// stores returned by newStores, so the same suite covers each Store
+// implementation.
+func testProductResource(t *testing.T, newStores func(t *testing.T) (ProductStore, UserStore)) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"create", "POST", "/products", `{"name":"Pen","price":1.5}`, http.StatusCreated},
+		{"create duplicate", "POST", "/products", `{"name":"Pen","price":2}`, http.StatusConflict},
+		{"create invalid", "POST", "/products", `{"name":"","price":0}`, http.StatusUnprocessableEntity},
+		{"create malformed", "POST", "/products", `{"name":`, http.StatusBadRequest},
+		{"create unknown field", "POST", "/products", `{"name":"Ink","price":1,"colour":"blue"}`, http.StatusBadRequest},
+		{"create two objects", "POST", "/products", `{"name":"Ink","price":1}{}`, http.StatusBadRequest},
+		{"create too large", "POST", "/products", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `","price":1}`, http.StatusRequestEntityTooLarge},
+		{"list", "GET", "/products", "", http.StatusOK},
+		{"get", "GET", "/products/1", "", http.StatusOK},
+		{"get missing", "GET", "/products/99", "", http.StatusNotFound},
+		{"get bad id", "GET", "/products/abc", "", http.StatusNotFound},
+		{"update", "PUT", "/products/1", `{"name":"Pencil","price":0.5}`, http.StatusOK},
+		{"update missing", "PUT", "/products/99", `{"name":"Pencil","price":0.5}`, http.StatusNotFound},
+		{"update invalid", "PUT", "/products/1", `{"name":"Pencil","price":-1}`, http.StatusUnprocessableEntity},
+		{"delete", "DELETE", "/products/1", "", http.StatusNoContent},
+		{"delete again", "DELETE", "/products/1", "", http.StatusNotFound},
+	}
+
+	products, users := newStores(t)
+	mux := routes(products, users)
+
+	// The cases run in order against one store, so later cases see the
+	// records created by earlier ones.
+	for _, tt := range tests {
+		rec := do(t, mux, tt.method, tt.path, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: %s %s = %d, want %d\n%s", tt.name, tt.method, tt.path, rec.Code, tt.status, rec.Body)
+		}
+		if rec.Code >= 400 && rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: error Content-Type = %q", tt.name, rec.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func newMemStores(t *testing.T) (ProductStore, UserStore) {
+	return NewMemProductStore(), NewMemUserStore()
+}
+
+func TestProductResource(t *testing.T) {
+	testProductResource(t, newMemStores)
+}
+
+func TestCreateReturnsRecord(t *testing.T) {
+	mux := routes(newMemStores(t))
+
+	rec := do(t, mux, "POST", "/users", `{"name":"Gary","email":"gary@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d\n%s", rec.Code, rec.Body)
+	}
+	created := decodeData[User](t, rec)
+	if created.ID != 1 || created.Email != "gary@example.com" {
+		t.Errorf("created = %+v", created)
+	}
+
+	list := decodeData[[]User](t, do(t, mux, "GET", "/users", ""))
+	if len(list) != 1 || list[0] != created {
+		t.Errorf("list = %+v", list)
+	}
+}
+
+func TestDecodeHidesDecoderErrors(t *testing.T) {
+	mux := routes(newMemStores(t))
+
+	rec := do(t, mux, "POST", "/products", `{"name":"Pen","price":"free"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var p problem
+	err := json.Unmarshal(rec.Body.Bytes(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(p.Detail, "json:") || strings.Contains(p.Detail, "Go") {
+		t.Errorf("detail leaks decoder error: %q", p.Detail)
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/xml"
+	"flag"
+	"log"
 	"net/http"
 )
 
@@ -12,25 +15,53 @@ type User struct {
 }
 
 type Product struct {
-	ID    int     `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	XMLName xml.Name `json:"-" xml:"product"`
+	ID      int      `json:"id" xml:"id,attr"`
+	Name    string   `json:"name" xml:"name"`
+	Price   float64  `json:"price" xml:"price"`
 }
 
-func main() {
-	http.HandleFunc("/product", func(w http.ResponseWriter, r *http.Request) {
+func routes(products ProductStore, users UserStore) *http.ServeMux {
+	mux := http.NewServeMux()
+	encoders := newEncoderRegistry()
+
+	mux.HandleFunc("GET /product", func(w http.ResponseWriter, r *http.Request) {
 		product := Product{
 			ID:    10,
 			Name:  "Notebook",
 			Price: 12.99,
 		}
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(product)
-		if err != nil {
-			http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
-			return
-		}
+		writeNegotiated(w, r, encoders, http.StatusOK, product)
 	})
 
-	http.ListenAndServe(":8080", nil)
+	resource[Product]{name: "product", store: products}.register(mux, "/products")
+	resource[User]{name: "user", store: users}.register(mux, "/users")
+
+	return mux
+}
+
+func main() {
+	dsn := flag.String("db", ":memory:", "SQLite database file, or :memory:")
+	flag.Parse()
+
+	db, err := OpenDB(*dsn)
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	err = Migrate(ctx, db)
+	if err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	err = SeedIfEmpty(ctx, db)
+	if err != nil {
+		log.Fatal("Failed to seed database:", err)
+	}
+
+	stores := NewStores(db)
+	mux := routes(stores.Products, stores.Users)
+
+	http.ListenAndServe(":8080", mux)
 }
@@ -0,0 +1,5 @@
+CREATE TABLE products (
+    id    INTEGER PRIMARY KEY AUTOINCREMENT,
+    name  TEXT    NOT NULL UNIQUE,
+    price REAL    NOT NULL
+);
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// encodeFunc writes v to w in one media type.
+type encodeFunc func(w io.Writer, v any) error
+
+type format struct {
+	mediaType string
+	encode    encodeFunc
+}
+
+// encoderRegistry lists the formats a handler can produce. The first
+// registered format is used when the client accepts anything.
+type encoderRegistry struct {
+	formats []format
+}
+
+func (reg *encoderRegistry) register(mediaType string, encode encodeFunc) {
+	reg.formats = append(reg.formats, format{mediaType: mediaType, encode: encode})
+}
+
+// csvRecord is implemented by values that can be written as CSV.
+type csvRecord interface {
+	csvHeader() []string
+	csvRow() []string
+}
+
+func (p Product) csvHeader() []string {
+	return []string{"id", "name", "price"}
+}
+
+func (p Product) csvRow() []string {
+	return []string{strconv.Itoa(p.ID), p.Name, strconv.FormatFloat(p.Price, 'f', 2, 64)}
+}
+
+func (p Product) String() string {
+	return fmt.Sprintf("Product %d: %s ($%.2f)", p.ID, p.Name, p.Price)
+}
+
+func newEncoderRegistry() *encoderRegistry {
+	reg := &encoderRegistry{}
+
+	reg.register("application/json", func(w io.Writer, v any) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+	reg.register("application/xml", func(w io.Writer, v any) error {
+		return xml.NewEncoder(w).Encode(v)
+	})
+	reg.register("text/csv", func(w io.Writer, v any) error {
+		record, ok := v.(csvRecord)
+		if !ok {
+			return fmt.Errorf("%T cannot be written as CSV", v)
+		}
+		cw := csv.NewWriter(w)
+		cw.Write(record.csvHeader())
+		cw.Write(record.csvRow())
+		cw.Flush()
+		return cw.Error()
+	})
+	reg.register("text/plain", func(w io.Writer, v any) error {
+		_, err := fmt.Fprintln(w, v)
+		return err
+	})
+
+	return reg
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept splits an Accept header into media ranges, ordered from
+// most to least preferred. More specific ranges win ties.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err == nil && parsed >= 0 && parsed <= 1 {
+					q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+	return ranges
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matches(accepted, offered string) bool {
+	if accepted == "*/*" || accepted == offered {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(accepted, "*")
+	return ok && strings.HasPrefix(offered, prefix)
+}
+
+// negotiate picks the format that best satisfies the Accept header. It
+// returns false when no registered format is acceptable.
+//
+// Each format takes the q-value of the most specific range that matches
+// it, so "application/*;q=0, */*" refuses JSON even though */* would
+// accept it. The highest q-value wins. Ties go to the format whose range
+// comes first in the list from parseAccept, which is sorted by q-value
+// and then specificity, so the client's order only decides between
+// equally specific ranges. Formats matched by the same range go in
+// registration order.
+func (reg *encoderRegistry) negotiate(header string) (format, bool) {
+	if strings.TrimSpace(header) == "" {
+		return reg.formats[0], true
+	}
+
+	ranges := parseAccept(header)
+
+	var best format
+	bestQ, bestRank := 0.0, len(ranges)
+	for _, f := range reg.formats {
+		q, rank, ok := quality(ranges, f.mediaType)
+		if !ok || q == 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && rank < bestRank) {
+			best, bestQ, bestRank = f, q, rank
+		}
+	}
+	return best, bestQ > 0
+}
+
+// quality returns the q-value of the most specific range in ranges that
+// matches mediaType, along with that range's position. ok is false when
+// no range matches.
+func quality(ranges []acceptRange, mediaType string) (q float64, rank int, ok bool) {
+	bestSpecificity := -1
+	for i, ar := range ranges {
+		if !matches(ar.mediaType, mediaType) {
+			continue
+		}
+		if s := specificity(ar.mediaType); s > bestSpecificity {
+			bestSpecificity, q, rank, ok = s, ar.q, i, true
+		}
+	}
+	return q, rank, ok
+}
+
+// writeNegotiated renders v in the format the client prefers, or writes
+// a 406 if none of the registered formats are acceptable.
+func writeNegotiated(w http.ResponseWriter, r *http.Request, reg *encoderRegistry, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+
+	f, ok := reg.negotiate(r.Header.Get("Accept"))
+	if !ok {
+		types := make([]string, len(reg.formats))
+		for i, f := range reg.formats {
+			types[i] = f.mediaType
+		}
+		writeProblem(w, r, http.StatusNotAcceptable, "supported types: "+strings.Join(types, ", "))
+		return
+	}
+
+	var buf bytes.Buffer
+	err := f.encode(&buf, v)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", f.mediaType, err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", f.mediaType+"; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	reg := newEncoderRegistry()
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/csv, application/json", "text/csv"},
+		{"text/plain;q=0.5, application/json", "application/json"},
+		{"text/*;q=0.3, text/plain", "text/plain"},
+		{"*/*;q=0.1, text/csv", "text/csv"},
+		{"application/*;q=0, */*", "text/csv"},
+		{"application/json;q=0, application/*", "application/xml"},
+		{"text/*;q=0, text/csv", "text/csv"},
+		{"TEXT/PLAIN", "text/plain"},
+		{"application/json;q=0", ""},
+		{"*/*;q=0", ""},
+		{"image/png", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			f, ok := reg.negotiate(tt.accept)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("negotiate = %s, want no match", f.mediaType)
+				}
+				return
+			}
+			if !ok || f.mediaType != tt.want {
+				t.Errorf("negotiate = %q (%v), want %q", f.mediaType, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteNegotiated(t *testing.T) {
+	reg := newEncoderRegistry()
+	product := Product{ID: 1, Name: "Pen", Price: 1.5}
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"application/*;q=0, */*", http.StatusOK, "text/csv; charset=utf-8"},
+		{"image/png", http.StatusNotAcceptable, "application/problem+json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/product", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			writeNegotiated(rec, req, reg, http.StatusOK, product)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+		})
+	}
+}
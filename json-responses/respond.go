@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// envelope wraps a response body as {"data": ...}.
+type envelope[T any] struct {
+	Data T `json:"data"`
+}
+
+// problem is an RFC 9457 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Errors holds field-level validation errors, keyed by field name.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// writeJSON encodes v into a buffer before touching the response, so an
+// encoding failure can still become a clean 500.
+func writeJSON[T any](w http.ResponseWriter, status int, v T) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		log.Println("Failed to encode JSON:", err)
+		writeProblem(w, nil, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// writeData writes v wrapped in a {"data": ...} envelope.
+func writeData[T any](w http.ResponseWriter, status int, v T) {
+	writeJSON(w, status, envelope[T]{Data: v})
+}
+
+// writeProblem writes an application/problem+json error response. r may
+// be nil when the request path is not known.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	sendProblem(w, newProblem(r, status, detail))
+}
+
+// writeValidationProblem writes a 422 problem listing each invalid field.
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, errs map[string]string) {
+	p := newProblem(r, http.StatusUnprocessableEntity, "one or more fields are invalid")
+	p.Errors = errs
+	sendProblem(w, p)
+}
+
+func newProblem(r *http.Request, status int, detail string) problem {
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+func sendProblem(w http.ResponseWriter, p problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)+1))
+	w.WriteHeader(p.Status)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestWriteData(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeData(rec, http.StatusCreated, map[string]int{"id": 7})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	var body envelope[map[string]int]
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Data["id"] != 7 {
+		t.Errorf("data = %v", body.Data)
+	}
+}
+
+func TestWriteJSONEncodeFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(http.ResponseWriter)
+	}{
+		{"channel", func(w http.ResponseWriter) { writeJSON(w, http.StatusOK, make(chan int)) }},
+		{"infinity", func(w http.ResponseWriter) { writeData(w, http.StatusOK, math.Inf(1)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+
+			var p problem
+			err := json.Unmarshal(rec.Body.Bytes(), &p)
+			if err != nil {
+				t.Fatalf("body is not a single problem document: %v\n%s", err, rec.Body)
+			}
+			if p.Status != http.StatusInternalServerError || p.Title != "Internal Server Error" {
+				t.Errorf("problem = %+v", p)
+			}
+		})
+	}
+}
+
+func TestWriteValidationProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/products", nil)
+	writeValidationProblem(rec, req, map[string]string{"name": "is required"})
+
+	var p problem
+	err := json.Unmarshal(rec.Body.Bytes(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || p.Instance != "/products" || p.Errors["name"] != "is required" {
+		t.Errorf("status %d, problem %+v", rec.Code, p)
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()); got != want {
+		t.Errorf("Content-Length = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Stores struct {
+	Products ProductStore
+	Users    UserStore
+}
+
+// NewStores returns stores backed by db. Run Migrate first.
+func NewStores(db *sql.DB) Stores {
+	return Stores{
+		Products: &sqlProductStore{db: db},
+		Users:    &sqlUserStore{db: db},
+	}
+}
+
+// storeErr maps database errors onto the store's sentinel errors.
+func storeErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ErrConflict
+	}
+	return err
+}
+
+// rowsAffected returns ErrNotFound when a statement changed no rows.
+func rowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type sqlProductStore struct {
+	db *sql.DB
+}
+
+func (s *sqlProductStore) List(ctx context.Context) ([]Product, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, price FROM products ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []Product{}
+	for rows.Next() {
+		var p Product
+		err := rows.Scan(&p.ID, &p.Name, &p.Price)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (s *sqlProductStore) Get(ctx context.Context, id int) (Product, error) {
+	var p Product
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, price FROM products WHERE id = ?`, id).
+		Scan(&p.ID, &p.Name, &p.Price)
+	return p, storeErr(err)
+}
+
+func (s *sqlProductStore) Create(ctx context.Context, p Product) (Product, error) {
+	result, err := s.db.ExecContext(ctx, `INSERT INTO products (name, price) VALUES (?, ?)`, p.Name, p.Price)
+	if err != nil {
+		return Product{}, storeErr(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Product{}, err
+	}
+	p.ID = int(id)
+	return p, nil
+}
+
+func (s *sqlProductStore) Update(ctx context.Context, id int, p Product) (Product, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE products SET name = ?, price = ? WHERE id = ?`, p.Name, p.Price, id)
+	if err != nil {
+		return Product{}, storeErr(err)
+	}
+	err = rowsAffected(result)
+	if err != nil {
+		return Product{}, err
+	}
+	p.ID = id
+	return p, nil
+}
+
+func (s *sqlProductStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+type sqlUserStore struct {
+	db *sql.DB
+}
+
+func (s *sqlUserStore) List(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, email FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		err := rows.Scan(&u.ID, &u.Name, &u.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlUserStore) Get(ctx context.Context, id int) (User, error) {
+	var u User
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, email FROM users WHERE id = ?`, id).
+		Scan(&u.ID, &u.Name, &u.Email)
+	return u, storeErr(err)
+}
+
+func (s *sqlUserStore) Create(ctx context.Context, u User) (User, error) {
+	result, err := s.db.ExecContext(ctx, `INSERT INTO users (name, email) VALUES (?, ?)`, u.Name, u.Email)
+	if err != nil {
+		return User{}, storeErr(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	u.ID = int(id)
+	return u, nil
+}
+
+func (s *sqlUserStore) Update(ctx context.Context, id int, u User) (User, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET name = ?, email = ? WHERE id = ?`, u.Name, u.Email, id)
+	if err != nil {
+		return User{}, storeErr(err)
+	}
+	err = rowsAffected(result)
+	if err != nil {
+		return User{}, err
+	}
+	u.ID = id
+	return u, nil
+}
+
+func (s *sqlUserStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
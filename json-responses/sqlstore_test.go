@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newSQLStores opens a fresh in-memory database, migrates it and
+// returns stores backed by it.
+func newSQLStores(t *testing.T) (ProductStore, UserStore) {
+	t.Helper()
+
+	db, err := OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := NewStores(db)
+	return stores.Products, stores.Users
+}
+
+func TestSQLProductResource(t *testing.T) {
+	testProductResource(t, newSQLStores)
+}
+
+func TestMigrateAndSeedAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for range 2 {
+		err = Migrate(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = SeedIfEmpty(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var version int
+	err = db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("schema version = %d, want 2", version)
+	}
+
+	products, err := NewStores(db).Products.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 3 {
+		t.Errorf("seeded %d products, want 3", len(products))
+	}
+}
+
+// TestStoresAgree runs the same operations against the memory and SQL
+// stores and checks they report the same results and errors.
+func TestStoresAgree(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStores := range map[string]func(*testing.T) (ProductStore, UserStore){
+		"memory": newMemStores,
+		"sqlite": newSQLStores,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, users := newStores(t)
+
+			gary, err := users.Create(ctx, User{Name: "Gary", Email: "gary@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gary.ID != 1 {
+				t.Errorf("ID = %d, want 1", gary.ID)
+			}
+
+			_, err = users.Create(ctx, User{Name: "Other Gary", Email: "gary@example.com"})
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("duplicate email: err = %v, want ErrConflict", err)
+			}
+
+			ann, err := users.Create(ctx, User{Name: "Ann", Email: "ann@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = users.Update(ctx, ann.ID, User{Name: "Ann", Email: "gary@example.com"})
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("update to taken email: err = %v, want ErrConflict", err)
+			}
+
+			_, err = users.Get(ctx, 99)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("get missing: err = %v, want ErrNotFound", err)
+			}
+			err = users.Delete(ctx, 99)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("delete missing: err = %v, want ErrNotFound", err)
+			}
+
+			list, err := users.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 || list[0].ID != gary.ID || list[1].ID != ann.ID {
+				t.Errorf("list = %+v", list)
+			}
+		})
+	}
+}
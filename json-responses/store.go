@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	ErrConflict = errors.New("record already exists")
+)
+
+// Store holds records of type T, identified by an integer ID.
+type Store[T any] interface {
+	List(ctx context.Context) ([]T, error)
+	Get(ctx context.Context, id int) (T, error)
+	Create(ctx context.Context, v T) (T, error)
+	Update(ctx context.Context, id int, v T) (T, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type ProductStore = Store[Product]
+
+type UserStore = Store[User]
+
+// memStore is a Store kept in memory and safe for concurrent use.
+// unique returns the value that must not repeat between records, such as
+// an email address.
+type memStore[T any] struct {
+	mu      sync.RWMutex
+	records map[int]T
+	nextID  int
+	setID   func(*T, int)
+	unique  func(T) string
+}
+
+func newMemStore[T any](setID func(*T, int), unique func(T) string) *memStore[T] {
+	return &memStore[T]{
+		records: make(map[int]T),
+		nextID:  1,
+		setID:   setID,
+		unique:  unique,
+	}
+}
+
+func NewMemProductStore() ProductStore {
+	return newMemStore(
+		func(p *Product, id int) { p.ID = id },
+		func(p Product) string { return p.Name },
+	)
+}
+
+func NewMemUserStore() UserStore {
+	return newMemStore(
+		func(u *User, id int) { u.ID = id },
+		func(u User) string { return u.Email },
+	)
+}
+
+func (s *memStore[T]) List(ctx context.Context) ([]T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	list := make([]T, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, s.records[id])
+	}
+	return list, nil
+}
+
+func (s *memStore[T]) Get(ctx context.Context, id int) (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.records[id]
+	if !ok {
+		return v, ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *memStore[T]) Create(ctx context.Context, v T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.taken(v, 0) {
+		var zero T
+		return zero, ErrConflict
+	}
+
+	s.setID(&v, s.nextID)
+	s.records[s.nextID] = v
+	s.nextID++
+	return v, nil
+}
+
+func (s *memStore[T]) Update(ctx context.Context, id int, v T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero T
+	if _, ok := s.records[id]; !ok {
+		return zero, ErrNotFound
+	}
+	if s.taken(v, id) {
+		return zero, ErrConflict
+	}
+
+	s.setID(&v, id)
+	s.records[id] = v
+	return v, nil
+}
+
+func (s *memStore[T]) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// taken reports whether another record, other than the one with ID
+// skip, already uses v's unique value. The caller must hold s.mu.
+func (s *memStore[T]) taken(v T, skip int) bool {
+	key := s.unique(v)
+	for id, existing := range s.records {
+		if id != skip && s.unique(existing) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthCheck is one named startup dependency.
+type healthCheck struct {
+	Name      string
+	Run       func(ctx context.Context) error
+	DependsOn []string
+
+	// Timeout limits each attempt. Retries is the number of extra
+	// attempts after the first, waiting Backoff before the first retry
+	// and twice as long before each one after that.
+	Timeout time.Duration
+	Retries int
+	Backoff time.Duration
+
+	// Critical checks must pass for the service to be ready. A failing
+	// non-critical check is reported but does not block readiness.
+	Critical bool
+}
+
+type checkStatus string
+
+const (
+	statusPending checkStatus = "pending"
+	statusOK      checkStatus = "ok"
+	statusFailed  checkStatus = "failed"
+	statusSkipped checkStatus = "skipped"
+)
+
+type checkResult struct {
+	Status   checkStatus `json:"status"`
+	Critical bool        `json:"critical"`
+	Attempts int         `json:"attempts"`
+	Error    string      `json:"error,omitempty"`
+	Duration string      `json:"duration,omitempty"`
+}
+
+// healthChecker runs checks in dependency order and remembers the
+// outcome of each for the /healthz and /readyz endpoints.
+type healthChecker struct {
+	checks []healthCheck
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	results map[string]checkResult
+	done    bool
+}
+
+func newHealthChecker(logger *slog.Logger, checks ...healthCheck) (*healthChecker, error) {
+	ordered, err := orderChecks(checks)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]checkResult, len(checks))
+	for _, c := range checks {
+		results[c.Name] = checkResult{Status: statusPending, Critical: c.Critical}
+	}
+	return &healthChecker{checks: ordered, logger: logger, results: results}, nil
+}
+
+// orderChecks sorts checks so every check comes after the ones it
+// depends on. It fails on unknown dependencies and cycles.
+func orderChecks(checks []healthCheck) ([]healthCheck, error) {
+	byName := make(map[string]healthCheck, len(checks))
+	for _, c := range checks {
+		if _, dup := byName[c.Name]; dup {
+			return nil, fmt.Errorf("health check %q defined twice", c.Name)
+		}
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var ordered []healthCheck
+
+	// path holds the checks currently being visited, so a cycle can be
+	// reported from where it starts.
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			cycle := append(slices.Clone(path[slices.Index(path, name):]), name)
+			return fmt.Errorf("health check dependency cycle: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range byName[name].DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("health check %q depends on unknown check %q", name, dep)
+			}
+			err := visit(dep)
+			if err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		ordered = append(ordered, byName[name])
+		return nil
+	}
+
+	for _, c := range checks {
+		err := visit(c.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// runAll runs every check once, in dependency order. A check is skipped
+// when a critical dependency did not pass; a failed non-critical
+// dependency is assumed to have a fallback.
+func (hc *healthChecker) runAll(ctx context.Context) {
+	for _, c := range hc.checks {
+		if dep, ok := hc.failedDependency(c); ok {
+			hc.logger.WarnContext(ctx, "Skipping health check", "check", c.Name, "dependency", dep)
+			hc.setResult(c.Name, checkResult{Status: statusSkipped, Critical: c.Critical, Error: "dependency " + dep + " did not pass"})
+			continue
+		}
+		hc.setResult(c.Name, hc.run(ctx, c))
+	}
+
+	hc.mu.Lock()
+	hc.done = true
+	hc.mu.Unlock()
+}
+
+func (hc *healthChecker) failedDependency(c healthCheck) (string, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	for _, dep := range c.DependsOn {
+		r := hc.results[dep]
+		if r.Critical && r.Status != statusOK {
+			return dep, true
+		}
+	}
+	return "", false
+}
+
+func (hc *healthChecker) run(ctx context.Context, c healthCheck) checkResult {
+	start := time.Now()
+	backoff := c.Backoff
+	result := checkResult{Critical: c.Critical}
+
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				result.Status = statusFailed
+				result.Error = ctx.Err().Error()
+				return result
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		result.Attempts++
+		err := attemptCheck(ctx, c)
+		if err == nil {
+			hc.logger.InfoContext(ctx, "Health check passed", "check", c.Name, "attempts", result.Attempts)
+			result.Status = statusOK
+			result.Duration = time.Since(start).String()
+			return result
+		}
+
+		hc.logger.WarnContext(ctx, "Health check attempt failed",
+			"check", c.Name, "attempt", result.Attempts, "max_attempts", c.Retries+1, "error", err)
+		result.Error = err.Error()
+	}
+
+	level := slog.LevelWarn
+	if c.Critical {
+		level = slog.LevelError
+	}
+	hc.logger.Log(ctx, level, "Health check failed", "check", c.Name, "critical", c.Critical, "error", result.Error)
+
+	result.Status = statusFailed
+	result.Duration = time.Since(start).String()
+	return result
+}
+
+// attemptCheck runs c once, giving up after its timeout even if Run
+// ignores the context.
+func attemptCheck(ctx context.Context, c healthCheck) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (hc *healthChecker) setResult(name string, r checkResult) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.results[name] = r
+}
+
+// ready reports whether startup has finished and every critical check
+// passed.
+func (hc *healthChecker) ready() bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	if !hc.done {
+		return false
+	}
+	for _, r := range hc.results {
+		if r.Critical && r.Status != statusOK {
+			return false
+		}
+	}
+	return true
+}
+
+func (hc *healthChecker) writeStatus(w http.ResponseWriter, status int, overall string) {
+	hc.mu.RLock()
+	body := struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}{overall, hc.results}
+	data, err := json.Marshal(body)
+	hc.mu.RUnlock()
+
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// healthz reports that the process is alive. It always returns 200 and
+// includes each check's status for information.
+func (hc *healthChecker) healthz(w http.ResponseWriter, r *http.Request) {
+	hc.writeStatus(w, http.StatusOK, "ok")
+}
+
+// readyz returns 200 when the service can take traffic and 503 while
+// startup is running or a critical check has failed.
+func (hc *healthChecker) readyz(w http.ResponseWriter, r *http.Request) {
+	if hc.ready() {
+		hc.writeStatus(w, http.StatusOK, "ready")
+		return
+	}
+	hc.writeStatus(w, http.StatusServiceUnavailable, "not ready")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// flakyDependency fails until it has been called failures times, and
+// records when each call happened.
+type flakyDependency struct {
+	mu       sync.Mutex
+	failures int
+	calls    []time.Time
+}
+
+func (f *flakyDependency) run(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, time.Now())
+	if len(f.calls) <= f.failures {
+		return errors.New("not reachable")
+	}
+	return nil
+}
+
+func ok(ctx context.Context) error { return nil }
+
+func fail(ctx context.Context) error { return errors.New("down") }
+
+func TestHealthCheckRetriesWithBackoff(t *testing.T) {
+	db := &flakyDependency{failures: 2}
+	hc, err := newHealthChecker(discardLogger, healthCheck{
+		Name:     "database",
+		Run:      db.run,
+		Retries:  3,
+		Backoff:  20 * time.Millisecond,
+		Critical: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.runAll(context.Background())
+
+	r := hc.results["database"]
+	if r.Status != statusOK || r.Attempts != 3 {
+		t.Fatalf("result = %+v, want ok after 3 attempts", r)
+	}
+
+	// Each wait is at least twice the one before it.
+	first, second := db.calls[1].Sub(db.calls[0]), db.calls[2].Sub(db.calls[1])
+	if first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Errorf("waits = %v, %v, want at least 20ms then 40ms", first, second)
+	}
+}
+
+func TestHealthCheckGivesUp(t *testing.T) {
+	db := &flakyDependency{failures: 10}
+	hc, err := newHealthChecker(discardLogger, healthCheck{
+		Name: "database", Run: db.run, Retries: 2, Backoff: time.Millisecond, Critical: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.runAll(context.Background())
+
+	r := hc.results["database"]
+	if r.Status != statusFailed || r.Attempts != 3 || r.Error != "not reachable" {
+		t.Errorf("result = %+v, want failed after 3 attempts", r)
+	}
+	if hc.ready() {
+		t.Error("ready with a failed critical check")
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+	hc, err := newHealthChecker(discardLogger, healthCheck{
+		Name: "slow", Run: hang, Timeout: 10 * time.Millisecond, Critical: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	hc.runAll(context.Background())
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("a check that ignores its context was not abandoned at the timeout")
+	}
+	if r := hc.results["slow"]; r.Status != statusFailed || !strings.Contains(r.Error, "deadline") {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestHealthCheckSkipsDependents(t *testing.T) {
+	var ranWarmup, ranUser, ranReport bool
+	hc, err := newHealthChecker(discardLogger,
+		healthCheck{Name: "database", Run: fail, Critical: true},
+		healthCheck{Name: "user", DependsOn: []string{"database"}, Critical: true,
+			Run: func(ctx context.Context) error { ranUser = true; return nil }},
+		healthCheck{Name: "cache", Run: fail},
+		healthCheck{Name: "report", DependsOn: []string{"cache"},
+			Run: func(ctx context.Context) error { ranReport = true; return nil }},
+		healthCheck{Name: "warmup", DependsOn: []string{"user"},
+			Run: func(ctx context.Context) error { ranWarmup = true; return nil }},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.runAll(context.Background())
+
+	if ranUser || ranWarmup {
+		t.Error("checks depending on a failed critical check should not run")
+	}
+	if hc.results["user"].Status != statusSkipped || hc.results["warmup"].Status != statusSkipped {
+		t.Errorf("results = %+v", hc.results)
+	}
+	if !ranReport || hc.results["report"].Status != statusOK {
+		t.Error("a failed non-critical dependency should not block its dependents")
+	}
+}
+
+func TestOrderChecks(t *testing.T) {
+	checks := []healthCheck{
+		{Name: "user", DependsOn: []string{"database"}},
+		{Name: "database", DependsOn: []string{"config"}},
+		{Name: "config"},
+	}
+	ordered, err := orderChecks(checks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range ordered {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "config,database,user" {
+		t.Errorf("order = %s", got)
+	}
+
+	tests := []struct {
+		name   string
+		checks []healthCheck
+		want   string
+	}{
+		{
+			"self",
+			[]healthCheck{{Name: "a", DependsOn: []string{"a"}}},
+			"health check dependency cycle: a -> a",
+		},
+		{
+			"two checks",
+			[]healthCheck{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			"health check dependency cycle: a -> b -> a",
+		},
+		{
+			"cycle after a prefix",
+			[]healthCheck{
+				{Name: "root", DependsOn: []string{"a"}},
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"c"}},
+				{Name: "c", DependsOn: []string{"a"}},
+			},
+			"health check dependency cycle: a -> b -> c -> a",
+		},
+		{
+			"unknown",
+			[]healthCheck{{Name: "a", DependsOn: []string{"missing"}}},
+			`health check "a" depends on unknown check "missing"`,
+		},
+		{
+			"duplicate",
+			[]healthCheck{{Name: "a"}, {Name: "a"}},
+			`health check "a" defined twice`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := orderChecks(tt.checks)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	release := make(chan struct{})
+	hc, err := newHealthChecker(discardLogger,
+		healthCheck{Name: "database", Critical: true, Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		}},
+		healthCheck{Name: "cache", Run: fail},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(h http.HandlerFunc) (int, string) {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest("GET", "/", nil))
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body.Status
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hc.runAll(context.Background())
+		close(done)
+	}()
+
+	if code, status := get(hc.readyz); code != http.StatusServiceUnavailable || status != "not ready" {
+		t.Errorf("readyz while starting = %d %s, want 503", code, status)
+	}
+	if code, _ := get(hc.healthz); code != http.StatusOK {
+		t.Errorf("healthz while starting = %d, want 200", code)
+	}
+
+	close(release)
+	<-done
+
+	// The non-critical cache failed, which does not block readiness.
+	if code, status := get(hc.readyz); code != http.StatusOK || status != "ready" {
+		t.Errorf("readyz after startup = %d %s, want 200", code, status)
+	}
+}
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 )
 
 func loadConfig() error {
@@ -17,11 +27,118 @@ func loadUser() error {
 	return nil
 }
 
+// requestIDs gives every request a new ID and stores it in the context
+// so that log lines written with *Context methods can be correlated. The
+// ID is also sent back in X-Request-ID. A client's own X-Request-ID is
+// not reused: it would have to be checked before it reaches the logs,
+// which the handlers-and-routes lesson shows.
+func requestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id := hex.EncodeToString(b)
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
+
 func main() {
-	log.Println("Starting service")
-	err := loadUser()
+	format := flag.String("log-format", "text", "log output format: text or json")
+	addr := flag.String("addr", ":8080", "address for the public HTTP server")
+	adminAddr := flag.String("admin-addr", "127.0.0.1:8081", "address for the admin HTTP server; keep it off public interfaces")
+	logFile := flag.String("log-file", "", "write logs to this file, rotating it, instead of stderr")
+	maxSize := flag.Int64("log-max-size", 10<<20, "rotate the log file after this many bytes")
+	maxAge := flag.Duration("log-max-age", 24*time.Hour, "rotate the log file after this long")
+	maxBackups := flag.Int("log-max-backups", 5, "number of compressed log files to keep")
+	flag.Parse()
+
+	var out io.Writer = os.Stderr
+	if *logFile != "" {
+		rf, err := newRotatingFile(*logFile, *maxSize, *maxAge, *maxBackups)
+		if err != nil {
+			log.Fatal("Failed to open log file:", err)
+		}
+		defer rf.Close()
+
+		rf.ReopenOnSIGHUP(func(err error) {
+			fmt.Fprintln(os.Stderr, "Failed to reopen log file:", err)
+		})
+		out = rf
+	}
+
+	// The shared handler lets everything through; each package logger
+	// applies its own level on top.
+	base := newHandler(*format, out, slog.LevelDebug)
+	levels := newLevels(base, slog.LevelInfo)
+
+	logger := levels.logger("main")
+	httpLog := levels.logger("http")
+
+	// log.Println and friends now go through slog too.
+	slog.SetDefault(logger)
+
+	logger.Info("Starting service")
+
+	checker, err := newHealthChecker(levels.logger("health"),
+		healthCheck{
+			Name:    "config",
+			Run:     func(ctx context.Context) error { return loadConfig() },
+			Timeout: time.Second,
+		},
+		healthCheck{
+			Name:      "database",
+			Run:       func(ctx context.Context) error { return connect() },
+			DependsOn: []string{"config"},
+			Timeout:   2 * time.Second,
+			Retries:   3,
+			Backoff:   500 * time.Millisecond,
+			Critical:  true,
+		},
+		healthCheck{
+			Name:      "user",
+			Run:       func(ctx context.Context) error { return loadUser() },
+			DependsOn: []string{"database"},
+			Timeout:   time.Second,
+			Critical:  true,
+		},
+	)
 	if err != nil {
-		log.Fatal("Critical failure, shutting down:", err)
+		logger.Error("Invalid health checks", "error", err)
+		os.Exit(1)
 	}
-	log.Println("Service started successfully")
+
+	// Run the checks in the background so /healthz answers straight
+	// away and /readyz reports 503 until they pass.
+	go func() {
+		checker.runAll(context.Background())
+		if checker.ready() {
+			logger.Info("Service started successfully")
+		} else {
+			logger.Error("Service is not ready")
+		}
+	}()
+
+	// Changing log levels is an admin task, so it gets its own listener,
+	// which by default only accepts connections from this machine.
+	admin := http.NewServeMux()
+	admin.Handle("/debug/log-levels", levels)
+	go func() {
+		logger.Info("Admin server listening", "addr", *adminAddr)
+		err := http.ListenAndServe(*adminAddr, requestIDs(admin))
+		logger.Error("Admin server stopped", "error", err)
+		os.Exit(1)
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", checker.healthz)
+	mux.HandleFunc("GET /readyz", checker.readyz)
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		httpLog.DebugContext(r.Context(), "Handling hello", "user_agent", r.UserAgent())
+		httpLog.InfoContext(r.Context(), "Said hello", "token", r.URL.Query().Get("token"))
+		w.Write([]byte("Hello\n"))
+	})
+
+	logger.Info("Server listening", "addr", *addr)
+	log.Fatal(http.ListenAndServe(*addr, requestIDs(mux)))
 }
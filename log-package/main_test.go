@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDsCorrelateLogLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newHandler("json", &buf, slog.LevelDebug))
+
+	h := requestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "first")
+		logger.InfoContext(r.Context(), "second")
+	}))
+
+	var ids []string
+	for range 2 {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "forged")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		ids = append(ids, rec.Header().Get("X-Request-ID"))
+	}
+
+	if ids[0] == "" || ids[0] == "forged" || ids[0] == ids[1] {
+		t.Fatalf("response IDs = %q, want two new, different IDs", ids)
+	}
+
+	dec := json.NewDecoder(&buf)
+	for i := range 4 {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		// Both lines of a request carry the ID that was sent back.
+		if want := ids[i/2]; line["request_id"] != want {
+			t.Errorf("line %d (%v): request_id = %v, want %s", i, line["msg"], line["request_id"], want)
+		}
+	}
+}
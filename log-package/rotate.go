@@ -0,0 +1,389 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is an io.Writer that writes to a log file and starts a
+// new one when the current file grows past maxSize bytes or is older
+// than maxAge. Old files are gzipped and only the newest maxBackups are
+// kept. It is safe for concurrent use, so it can be passed to both
+// log.SetOutput and a slog handler.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	closed   bool
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+
+	mill chan struct{}
+	done chan struct{}
+}
+
+func newRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+		mill:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	err := rf.openExisting()
+	if err != nil {
+		return nil, err
+	}
+
+	go rf.runMill()
+	return rf, nil
+}
+
+// open opens the log file for appending. The caller must hold rf.mu.
+func (rf *rotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(rf.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.file = f
+	rf.size = info.Size()
+	rf.openedAt = rf.now()
+	return nil
+}
+
+// openExisting opens the log file at startup. A file that already has
+// logs in it was started when the newest backup was rotated out, so it
+// keeps that age and restarting the program does not reset maxAge. The
+// file system has no portable creation time, so with no backups to go
+// by the age starts now.
+func (rf *rotatingFile) openExisting() error {
+	err := rf.open()
+	if err != nil || rf.size == 0 {
+		return err
+	}
+
+	backups, err := rf.backups()
+	if err != nil || len(backups) == 0 {
+		return nil
+	}
+	if rotated, _, ok := rf.parseBackup(backups[0]); ok && rotated.Before(rf.openedAt) {
+		rf.openedAt = rotated
+	}
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return 0, os.ErrClosed
+	}
+	if rf.file == nil {
+		// A failed rotation or reopen left no file open. Try again
+		// instead of failing every write until the next SIGHUP.
+		err := rf.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	tooBig := rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize
+	tooOld := rf.maxAge > 0 && rf.now().Sub(rf.openedAt) >= rf.maxAge
+	if tooBig || tooOld {
+		err := rf.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to a timestamped backup and opens a
+// fresh one. Compression happens in the background. The caller must
+// hold rf.mu. If it fails, rf.file is left nil and the next Write
+// opens the file again.
+func (rf *rotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(rf.path, rf.backupName(rf.now()))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = rf.open()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case rf.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupName returns an unused backup name for a rotation at t. Two
+// rotations in the same millisecond would otherwise get the same name,
+// and the rename would replace the older backup, so later ones get a
+// -1, -2, ... suffix.
+func (rf *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, ext) + "-" + t.Format(backupTimeFormat)
+
+	name := base + ext
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+// exists reports whether something is at name. Other errors, such as a
+// missing directory, also stop Rename, which then reports them.
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// Reopen closes and reopens the file at the same path. Call it after an
+// external tool such as logrotate has moved the file away. If the file
+// was not moved, it keeps its age.
+func (rf *rotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+
+	var prev os.FileInfo
+	if rf.file != nil {
+		prev, _ = rf.file.Stat()
+		rf.file.Close()
+		rf.file = nil
+	}
+
+	openedAt := rf.openedAt
+	err := rf.open()
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		info, err := rf.file.Stat()
+		if err == nil && os.SameFile(prev, info) {
+			rf.openedAt = openedAt
+		}
+	}
+	return nil
+}
+
+// ReopenOnSIGHUP reopens the file every time the process receives
+// SIGHUP, which is how logrotate signals that it has moved the file.
+func (rf *rotatingFile) ReopenOnSIGHUP(onError func(error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				err := rf.Reopen()
+				if err != nil && onError != nil {
+					onError(err)
+				}
+			case <-rf.done:
+				signal.Stop(signals)
+				return
+			}
+		}
+	}()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return nil
+	}
+	rf.closed = true
+	close(rf.done)
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *rotatingFile) runMill() {
+	for {
+		select {
+		case <-rf.mill:
+			rf.compressAndPrune()
+		case <-rf.done:
+			return
+		}
+	}
+}
+
+// backups returns existing backup files, newest first. A name counts
+// as a backup only if it is the log path with a backup timestamp added,
+// optionally gzipped, so unrelated files that share the prefix are left
+// alone.
+func (rf *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(rf.path)
+	prefix := strings.TrimSuffix(rf.path, ext) + "-"
+
+	// One glob covers both plain and gzipped backups. Globbing for them
+	// separately lists gzipped files twice when the path has no
+	// extension.
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name    string
+		rotated time.Time
+		seq     int
+	}
+	var found []backup
+	for _, name := range matches {
+		if rotated, seq, ok := rf.parseBackup(name); ok {
+			found = append(found, backup{name, rotated, seq})
+		}
+	}
+
+	// A plain string sort would put app-T.000-1.log before app-T.000.log
+	// and -10 before -9, so compare the parsed parts.
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].rotated.Equal(found[j].rotated) {
+			return found[i].rotated.After(found[j].rotated)
+		}
+		return found[i].seq > found[j].seq
+	})
+
+	all := make([]string, len(found))
+	for i, b := range found {
+		all[i] = b.name
+	}
+	return all, nil
+}
+
+// parseBackup returns the rotation time and collision suffix in a backup
+// file name (0 if it has none), and false if name is not a backup of
+// rf.path.
+func (rf *rotatingFile) parseBackup(name string) (time.Time, int, bool) {
+	ext := filepath.Ext(rf.path)
+	stamp, ok := strings.CutPrefix(name, strings.TrimSuffix(rf.path, ext)+"-")
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	stamp, ok = strings.CutSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+
+	seq := 0
+	stamp, suffix, hasSuffix := strings.Cut(stamp, "-")
+	if hasSuffix {
+		n, err := strconv.Atoi(suffix)
+		if err != nil || n < 1 {
+			return time.Time{}, 0, false
+		}
+		seq = n
+	}
+
+	// Names are written with rf.now, which has no zone in the format.
+	t, err := time.ParseInLocation(backupTimeFormat, stamp, rf.now().Location())
+	return t, seq, err == nil
+}
+
+func (rf *rotatingFile) compressAndPrune() {
+	backups, err := rf.backups()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "log rotation:", err)
+		return
+	}
+
+	for i, name := range backups {
+		if rf.maxBackups > 0 && i >= rf.maxBackups {
+			os.Remove(name)
+			continue
+		}
+		if !strings.HasSuffix(name, ".gz") {
+			err := gzipFile(name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "log rotation:", err)
+			}
+		}
+	}
+}
+
+// gzipFile compresses name to name.gz and removes the original.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
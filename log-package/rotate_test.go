@@ -0,0 +1,274 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRotatingFile opens a rotatingFile with a fake clock and no
+// background mill, so tests can run compressAndPrune themselves.
+func newTestRotatingFile(t *testing.T, path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, *time.Time) {
+	t.Helper()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        func() time.Time { return now },
+		mill:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	err := rf.openExisting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rf.Close() })
+	return rf, &now
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	rf, now := newTestRotatingFile(t, filepath.Join(dir, "app.log"), 10, 0, 0)
+
+	rf.Write([]byte("12345678\n"))
+	*now = now.Add(time.Second)
+	rf.Write([]byte("abc\n"))
+
+	names := listDir(t, dir)
+	if len(names) != 2 || names[0] != "app-20260101T000001.000.log" || names[1] != "app.log" {
+		t.Fatalf("files = %v", names)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if string(data) != "abc\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
+
+func TestRotateSameMillisecondKeepsBackups(t *testing.T) {
+	dir := t.TempDir()
+	rf, _ := newTestRotatingFile(t, filepath.Join(dir, "app.log"), 2, 0, 0)
+
+	// The clock never moves, so every rotation wants the same name.
+	for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+		rf.Write([]byte(line))
+	}
+
+	want := []string{
+		"app-20260101T000000.000-1.log",
+		"app-20260101T000000.000-2.log",
+		"app-20260101T000000.000.log",
+		"app.log",
+	}
+	if got := listDir(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+
+	backups, err := rf.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 || filepath.Base(backups[0]) != want[1] || filepath.Base(backups[2]) != want[2] {
+		t.Errorf("backups = %v, want newest suffix first", backups)
+	}
+}
+
+func TestWriteRecoversFromFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	rf, _ := newTestRotatingFile(t, filepath.Join(dir, "app.log"), 2, 0, 0)
+
+	rf.Write([]byte("a\n"))
+
+	// A plain file where the log directory should be makes the rename
+	// during rotation fail.
+	os.RemoveAll(dir)
+	os.WriteFile(dir, nil, 0o644)
+	_, err := rf.Write([]byte("b\n"))
+	if err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	os.Remove(dir)
+	_, err = rf.Write([]byte("c\n"))
+	if err != nil {
+		t.Fatalf("write after failed rotation: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if string(data) != "c\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	rf, now := newTestRotatingFile(t, filepath.Join(dir, "app.log"), 0, time.Hour, 0)
+
+	rf.Write([]byte("first\n"))
+	*now = now.Add(59 * time.Minute)
+	rf.Write([]byte("second\n"))
+	if len(listDir(t, dir)) != 1 {
+		t.Fatal("rotated too early")
+	}
+
+	*now = now.Add(time.Minute)
+	rf.Write([]byte("third\n"))
+	if len(listDir(t, dir)) != 2 {
+		t.Error("file older than maxAge was not rotated")
+	}
+}
+
+func TestReopenKeepsFileAge(t *testing.T) {
+	dir := t.TempDir()
+	rf, now := newTestRotatingFile(t, filepath.Join(dir, "app.log"), 0, time.Hour, 0)
+	started := rf.openedAt
+
+	// The file was written moments ago, so its ModTime is recent, but
+	// it is still 40 minutes old.
+	*now = now.Add(40 * time.Minute)
+	rf.Write([]byte("line\n"))
+
+	err := rf.Reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rf.openedAt.Equal(started) {
+		t.Errorf("openedAt = %v after Reopen, want %v", rf.openedAt, started)
+	}
+
+	*now = now.Add(20 * time.Minute)
+	rf.Write([]byte("line\n"))
+	if len(listDir(t, dir)) != 2 {
+		t.Error("reopened file older than maxAge was not rotated")
+	}
+}
+
+func TestReopenAfterMoveStartsNewAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	rf, now := newTestRotatingFile(t, path, 0, time.Hour, 0)
+
+	rf.Write([]byte("line\n"))
+	*now = now.Add(40 * time.Minute)
+
+	// logrotate moves the file away, then sends SIGHUP.
+	err := os.Rename(path, path+".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rf.Reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rf.openedAt.Equal(*now) {
+		t.Errorf("openedAt = %v, want the reopen time %v", rf.openedAt, *now)
+	}
+
+	*now = now.Add(30 * time.Minute)
+	rf.Write([]byte("line\n"))
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Errorf("files = %v, want only app.log and app.log.1", names)
+	}
+}
+
+func TestStartupAgeFromNewestBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	for _, name := range []string{"app.log", "app-20251231T220000.000.log.gz", "app-20251231T233000.000.log.gz"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("old line\n"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rf, _ := newTestRotatingFile(t, path, 0, time.Hour, 0)
+	want := time.Date(2025, 12, 31, 23, 30, 0, 0, time.UTC)
+	if !rf.openedAt.Equal(want) {
+		t.Errorf("openedAt = %v, want the newest rotation %v", rf.openedAt, want)
+	}
+}
+
+func TestStartupAgeWithoutBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	err := os.WriteFile(path, []byte("old line\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	err = os.Chtimes(path, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ModTime is the last write, not the start of the file, so it is
+	// not used.
+	rf, now := newTestRotatingFile(t, path, 0, time.Hour, 0)
+	if !rf.openedAt.Equal(*now) {
+		t.Errorf("openedAt = %v, want now %v", rf.openedAt, *now)
+	}
+}
+
+func TestCompressAndPrune(t *testing.T) {
+	for _, name := range []string{"app.log", "app"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			rf, now := newTestRotatingFile(t, filepath.Join(dir, name), 1, 0, 3)
+
+			// Unrelated files that share the prefix must survive.
+			os.WriteFile(filepath.Join(dir, name+"-notes.txt"), nil, 0o644)
+			os.WriteFile(filepath.Join(dir, "app-config"), nil, 0o644)
+
+			for i := range 5 {
+				*now = now.Add(time.Second)
+				rf.Write([]byte{'a' + byte(i)})
+				rf.compressAndPrune()
+			}
+
+			backups, err := rf.backups()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != 3 {
+				t.Fatalf("backups = %v, want 3", backups)
+			}
+			for _, b := range backups {
+				if !strings.HasSuffix(b, ".gz") {
+					t.Errorf("%s was not compressed", b)
+				}
+			}
+			if !strings.Contains(backups[0], "T000005") {
+				t.Errorf("newest backup = %s, want the one from 00:00:05", backups[0])
+			}
+
+			for _, keep := range []string{name + "-notes.txt", "app-config"} {
+				if _, err := os.Stat(filepath.Join(dir, keep)); err != nil {
+					t.Errorf("unrelated file %s removed", keep)
+				}
+			}
+		})
+	}
+}
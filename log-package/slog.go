@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// newHandler returns a JSON or text slog handler for w, filtered by
+// level and with sensitive values redacted.
+func newHandler(format string, w io.Writer, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return contextHandler{redactHandler{h}}
+}
+
+// sensitiveKeys are attribute keys whose values never reach the logs.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"authorization": true,
+	"cookie":        true,
+}
+
+// redactHandler replaces the values of sensitive attributes, including
+// ones nested in groups, with "[REDACTED]".
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redact(a)
+	}
+	return redactHandler{h.next.WithAttrs(clean)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.next.WithGroup(name)}
+}
+
+func redact(a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+
+	group := v.Group()
+	clean := make([]any, len(group))
+	for i, ga := range group {
+		clean[i] = redact(ga)
+	}
+	return slog.Group(a.Key, clean...)
+}
+
+type requestIDKey struct{}
+
+// withRequestID returns a copy of ctx carrying a request ID.
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// contextHandler adds the request ID from the context to every record
+// logged with one of the *Context methods, such as InfoContext.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.next.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.next.WithGroup(name)}
+}
+
+// levels holds one adjustable level per package, so a noisy package
+// can be turned down, or a broken one turned up to debug, at runtime.
+type levels struct {
+	mu       sync.Mutex
+	base     slog.Handler
+	fallback slog.Level
+	vars     map[string]*slog.LevelVar
+}
+
+func newLevels(base slog.Handler, fallback slog.Level) *levels {
+	return &levels{base: base, fallback: fallback, vars: make(map[string]*slog.LevelVar)}
+}
+
+// logger returns a logger for pkg that filters by that package's level.
+// The base handler must let every level through.
+func (l *levels) logger(pkg string) *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, ok := l.vars[pkg]
+	if !ok {
+		v = new(slog.LevelVar)
+		v.Set(l.fallback)
+		l.vars[pkg] = v
+	}
+	return slog.New(levelHandler{l.base, v}).With("package", pkg)
+}
+
+func (l *levels) set(pkg string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, ok := l.vars[pkg]; ok {
+		v.Set(level)
+	}
+}
+
+func (l *levels) snapshot() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make(map[string]string, len(l.vars))
+	for pkg, v := range l.vars {
+		out[pkg] = v.Level().String()
+	}
+	return out
+}
+
+// levelHandler drops records below its own level before they reach the
+// shared handler.
+type levelHandler struct {
+	next  slog.Handler
+	level slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.next.Enabled(ctx, level)
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.next.WithAttrs(attrs), h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.next.WithGroup(name), h.level}
+}
+
+// ServeHTTP lists package levels on GET and changes them on PUT with a
+// body such as {"db": "DEBUG"}.
+func (l *levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var changes map[string]string
+		err := json.NewDecoder(r.Body).Decode(&changes)
+		if err != nil {
+			http.Error(w, "Body must be a JSON object of package to level", http.StatusBadRequest)
+			return
+		}
+
+		// Check every entry before applying any, so a bad request
+		// changes nothing.
+		parsed := make(map[string]slog.Level, len(changes))
+		known := l.snapshot()
+		for pkg, text := range changes {
+			var level slog.Level
+			err := level.UnmarshalText([]byte(text))
+			if err != nil {
+				http.Error(w, "Unknown level for "+pkg+": "+text, http.StatusBadRequest)
+				return
+			}
+			if _, ok := known[pkg]; !ok {
+				http.Error(w, "Unknown package: "+pkg, http.StatusNotFound)
+				return
+			}
+			parsed[pkg] = level
+		}
+		for pkg, level := range parsed {
+			l.set(pkg, level)
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.snapshot())
+}
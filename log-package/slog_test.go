@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// secretValue is the value given to every sensitive attribute.
+const secretValue = "hunter2"
+
+type credentials struct {
+	user, password string
+}
+
+// LogValue exposes the password as a nested attribute, which must be
+// redacted after it is resolved.
+func (c credentials) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user", c.user), slog.String("password", c.password))
+}
+
+// logJSON runs log against a JSON handler and returns the decoded line.
+func logJSON(t *testing.T, log func(*slog.Logger)) map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log(slog.New(newHandler("json", &buf, slog.LevelDebug)))
+
+	if strings.Contains(buf.String(), secretValue) {
+		t.Errorf("secret leaked: %s", buf.String())
+	}
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+	return line
+}
+
+// lookup follows a dotted path, such as "req.auth.token", into line.
+func lookup(line map[string]any, path string) any {
+	var v any = line
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+		want map[string]any // path to expected value
+	}{
+		{"top level", func(l *slog.Logger) {
+			l.Info("login", "user", "ann", "password", secretValue)
+		}, map[string]any{"user": "ann", "password": "[REDACTED]"}},
+		{"key case", func(l *slog.Logger) {
+			l.Info("call", "Authorization", secretValue, "API_KEY", secretValue)
+		}, map[string]any{"Authorization": "[REDACTED]", "API_KEY": "[REDACTED]"}},
+		{"nested groups", func(l *slog.Logger) {
+			l.Info("request", slog.Group("req",
+				slog.String("path", "/login"),
+				slog.Group("auth", slog.String("token", secretValue), slog.Int("tries", 2)),
+			))
+		}, map[string]any{"req.path": "/login", "req.auth.token": "[REDACTED]", "req.auth.tries": float64(2)}},
+		{"whole group under a sensitive key", func(l *slog.Logger) {
+			l.Info("call", slog.Group("secret", slog.String("a", secretValue)))
+		}, map[string]any{"secret": "[REDACTED]"}},
+		{"With attrs", func(l *slog.Logger) {
+			l.With("cookie", secretValue, "user", "ann").Info("hello")
+		}, map[string]any{"cookie": "[REDACTED]", "user": "ann"}},
+		{"With group attrs", func(l *slog.Logger) {
+			l.With(slog.Group("session", slog.String("token", secretValue))).Info("hello")
+		}, map[string]any{"session.token": "[REDACTED]"}},
+		{"WithGroup", func(l *slog.Logger) {
+			l.WithGroup("db").With("password", secretValue).Info("connect", "secret", secretValue, "host", "localhost")
+		}, map[string]any{"db.password": "[REDACTED]", "db.secret": "[REDACTED]", "db.host": "localhost"}},
+		{"LogValuer", func(l *slog.Logger) {
+			l.Info("login", "creds", credentials{"ann", secretValue})
+		}, map[string]any{"creds.user": "ann", "creds.password": "[REDACTED]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := logJSON(t, tt.log)
+			for path, want := range tt.want {
+				if got := lookup(line, path); got != want {
+					t.Errorf("%s = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newHandler("text", &buf, slog.LevelDebug))
+	logger.With("token", secretValue).Info("hello", slog.Group("g", "password", secretValue))
+
+	if strings.Contains(buf.String(), secretValue) {
+		t.Errorf("secret leaked: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "token=[REDACTED]") || !strings.Contains(buf.String(), "g.password=[REDACTED]") {
+		t.Errorf("line = %q, want redacted token and g.password", buf.String())
+	}
+}
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	line := logJSON(t, func(l *slog.Logger) {
+		l.InfoContext(withRequestID(context.Background(), "abc"), "hello")
+	})
+	if line["request_id"] != "abc" {
+		t.Errorf("request_id = %v, want abc", line["request_id"])
+	}
+}
+
+func newTestLevels(t *testing.T) (*levels, *bytes.Buffer) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	l := newLevels(newHandler("text", &buf, slog.LevelDebug), slog.LevelInfo)
+	l.logger("db")
+	l.logger("http")
+	return l, &buf
+}
+
+func serveLevels(l *levels, method, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest(method, "/debug/log-levels", strings.NewReader(body)))
+	return rec
+}
+
+func TestLevelsEndpoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		want   map[string]string
+	}{
+		{"list", "GET", "", http.StatusOK, map[string]string{"db": "INFO", "http": "INFO"}},
+		{"change one", "PUT", `{"db":"DEBUG"}`, http.StatusOK, map[string]string{"db": "DEBUG", "http": "INFO"}},
+		{"change several", "PUT", `{"db":"warn","http":"ERROR"}`, http.StatusOK, map[string]string{"db": "WARN", "http": "ERROR"}},
+		{"offset level", "PUT", `{"db":"INFO+2"}`, http.StatusOK, map[string]string{"db": "INFO+2", "http": "INFO"}},
+		{"unknown level", "PUT", `{"db":"DEBUG","http":"LOUD"}`, http.StatusBadRequest, map[string]string{"db": "INFO", "http": "INFO"}},
+		{"unknown package", "PUT", `{"db":"DEBUG","cache":"DEBUG"}`, http.StatusNotFound, map[string]string{"db": "INFO", "http": "INFO"}},
+		{"malformed body", "PUT", `{"db":`, http.StatusBadRequest, map[string]string{"db": "INFO", "http": "INFO"}},
+		{"wrong method", "POST", `{}`, http.StatusMethodNotAllowed, map[string]string{"db": "INFO", "http": "INFO"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLevels(t)
+
+			rec := serveLevels(l, tt.method, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != "GET, PUT" {
+				t.Errorf("Allow = %q, want GET, PUT", rec.Header().Get("Allow"))
+			}
+
+			// A rejected change must leave every level as it was.
+			got := l.snapshot()
+			if len(got) != len(tt.want) {
+				t.Errorf("levels = %v, want %v", got, tt.want)
+			}
+			for pkg, level := range tt.want {
+				if got[pkg] != level {
+					t.Errorf("%s = %s, want %s", pkg, got[pkg], level)
+				}
+			}
+		})
+	}
+}
+
+func TestLevelsFilterPerPackage(t *testing.T) {
+	l, buf := newTestLevels(t)
+	db, httpLog := l.logger("db"), l.logger("http")
+
+	db.Debug("db before")
+	serveLevels(l, "PUT", `{"db":"DEBUG"}`)
+	db.Debug("db after")
+	httpLog.Debug("http after")
+
+	out := buf.String()
+	if strings.Contains(out, "db before") || strings.Contains(out, "http after") {
+		t.Errorf("debug lines logged below their package level:\n%s", out)
+	}
+	if !strings.Contains(out, "db after") || !strings.Contains(out, "package=db") {
+		t.Errorf("db debug line missing after raising its level:\n%s", out)
+	}
+}
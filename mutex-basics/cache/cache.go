@@ -0,0 +1,235 @@
+// Package cache provides a read-through cache. On a miss it calls a
+// loader to fetch the value, and concurrent misses for the same key
+// share a single loader call, so an expired hot key cannot flood the
+// backend with requests.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Loader fetches the value for key when it is not cached.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Options controls how long entries live and how many are kept.
+type Options struct {
+	// TTL is how long a loaded value is fresh.
+	TTL time.Duration
+
+	// Stale is how long after TTL a value may still be returned while
+	// it is reloaded in the background. Zero disables this.
+	Stale time.Duration
+
+	// ErrorTTL is how long a loader error is cached, so a failing key
+	// is not retried on every request. Zero disables this.
+	ErrorTTL time.Duration
+
+	// MaxEntries caps the number of keys. When full, the least
+	// recently used key is evicted. Zero means no limit.
+	MaxEntries int
+}
+
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	err        error
+	freshUntil time.Time
+	staleUntil time.Time
+	refreshing bool
+
+	// retryAfter holds off background refreshes after one fails, so
+	// ErrorTTL applies to a stale value too.
+	retryAfter time.Time
+}
+
+// Cache is a read-through cache that is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	load Loader[K, V]
+	opts Options
+
+	// now returns the current time. Tests can replace it with a fake
+	// clock.
+	now func() time.Time
+
+	mu    sync.Mutex
+	items map[K]*list.Element
+	lru   *list.List // front is most recently used
+
+	loads group[K, V]
+}
+
+// New returns an empty cache that calls load on a miss.
+func New[K comparable, V any](load Loader[K, V], opts Options) *Cache[K, V] {
+	return &Cache[K, V]{
+		load:  load,
+		opts:  opts,
+		now:   time.Now,
+		items: make(map[K]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Get returns the value for key. A fresh value, or a cached error, is
+// returned straight away. A stale value is returned straight away and
+// reloaded in the background. Otherwise Get calls the loader, sharing
+// the call with any other goroutine asking for the same key.
+//
+// If ctx is cancelled Get stops waiting, but the load carries on for
+// the other callers and its result is still cached.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		now := c.now()
+
+		value, err := e.value, e.err
+
+		if now.Before(e.freshUntil) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return value, err
+		}
+
+		if err == nil && now.Before(e.staleUntil) {
+			c.lru.MoveToFront(el)
+			if !e.refreshing && !now.Before(e.retryAfter) {
+				e.refreshing = true
+				go c.refresh(key)
+			}
+			c.mu.Unlock()
+			return value, nil
+		}
+	}
+	c.mu.Unlock()
+
+	return c.loads.do(ctx, key, c.loadFunc(ctx, key))
+}
+
+// refresh reloads a stale key in the background.
+func (c *Cache[K, V]) refresh(key K) {
+	ctx := context.Background()
+	c.loads.do(ctx, key, c.loadFunc(ctx, key))
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry[K, V]).refreshing = false
+	}
+	c.mu.Unlock()
+}
+
+// loadFunc returns the function the load group runs for key. It keeps
+// the values in ctx but not its cancellation, because the result is
+// shared with callers who are still waiting.
+func (c *Cache[K, V]) loadFunc(ctx context.Context, key K) func() (V, error) {
+	ctx = context.WithoutCancel(ctx)
+	return func() (V, error) {
+		// A caller that missed just before another load finished can
+		// reach the group after that load has left it. The value is
+		// already stored by then, so check again instead of loading it
+		// twice.
+		if e, ok := c.fresh(key); ok {
+			return e.value, e.err
+		}
+
+		v, err := c.load(ctx, key)
+		c.store(key, v, err)
+		return v, err
+	}
+}
+
+// fresh returns a copy of the entry for key if it has not expired.
+func (c *Cache[K, V]) fresh(key K) (entry[K, V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		if c.now().Before(e.freshUntil) {
+			c.lru.MoveToFront(el)
+			return *e, true
+		}
+	}
+	return entry[K, V]{}, false
+}
+
+// store saves the result of a load. A failed load does not replace a
+// value that can still be served stale, but the next refresh waits for
+// ErrorTTL.
+func (c *Cache[K, V]) store(key K, value V, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	el, ok := c.items[key]
+
+	var e *entry[K, V]
+	if ok {
+		e = el.Value.(*entry[K, V])
+	}
+
+	if err != nil {
+		if c.opts.ErrorTTL <= 0 {
+			return
+		}
+		if e != nil && e.err == nil && now.Before(e.staleUntil) {
+			e.retryAfter = now.Add(c.opts.ErrorTTL)
+			return
+		}
+	}
+
+	if e == nil {
+		e = &entry[K, V]{key: key}
+		el = c.lru.PushFront(e)
+		c.items[key] = el
+	} else {
+		c.lru.MoveToFront(el)
+	}
+
+	e.value, e.err = value, err
+	e.retryAfter = time.Time{}
+	if err != nil {
+		e.freshUntil = now.Add(c.opts.ErrorTTL)
+		e.staleUntil = e.freshUntil
+	} else {
+		e.freshUntil = now.Add(c.opts.TTL)
+		e.staleUntil = e.freshUntil.Add(c.opts.Stale)
+	}
+
+	c.evict()
+}
+
+// evict removes least recently used entries until the cache fits in
+// MaxEntries. The caller must hold c.mu.
+func (c *Cache[K, V]) evict() {
+	if c.opts.MaxEntries <= 0 {
+		return
+	}
+	for c.lru.Len() > c.opts.MaxEntries {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*entry[K, V]).key)
+	}
+}
+
+// Delete removes key, so the next Get loads it again.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.lru.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// Len returns the number of cached keys, including stale ones and
+// cached errors.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
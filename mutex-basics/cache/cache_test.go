@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClock is a fake clock that tests move forward by hand.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestCache returns a cache driven by a fake clock.
+func newTestCache[K comparable, V any](load Loader[K, V], opts Options) (*Cache[K, V], *testClock) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := New(load, opts)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestGetLoadsOnceUnderStampede(t *testing.T) {
+	// The gap between checking the cache and joining a load only shows
+	// up when callers really run in parallel.
+	if runtime.GOMAXPROCS(0) < 4 {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	}
+
+	for round := range 50 {
+		var calls atomic.Int32
+		// The loader returns at once, so late callers often arrive
+		// after the load has finished.
+		c := New(func(ctx context.Context, key string) (int, error) {
+			calls.Add(1)
+			return 42, nil
+		}, Options{TTL: time.Minute})
+
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for range 1000 {
+			wg.Go(func() {
+				<-start
+				v, err := c.Get(context.Background(), "key")
+				if err != nil || v != 42 {
+					t.Errorf("Get = %d, %v, want 42", v, err)
+				}
+			})
+		}
+		close(start)
+		wg.Wait()
+
+		if n := calls.Load(); n != 1 {
+			t.Fatalf("round %d: loader ran %d times, want 1", round, n)
+		}
+	}
+}
+
+func TestLateCallerUsesStoredValue(t *testing.T) {
+	var calls atomic.Int32
+	c, _ := newTestCache(func(ctx context.Context, key string) (int, error) {
+		calls.Add(1)
+		return 42, nil
+	}, Options{TTL: time.Minute})
+
+	ctx := context.Background()
+	c.Get(ctx, "key")
+
+	// Replay what Get does for a caller that saw a miss, but only
+	// reached the load group after the first load had finished.
+	v, err := c.loads.do(ctx, "key", c.loadFunc(ctx, "key"))
+	if v != 42 || err != nil {
+		t.Errorf("late caller got %d, %v, want 42", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	refreshed := make(chan struct{}, 1)
+
+	c, clock := newTestCache(func(ctx context.Context, key string) (int32, error) {
+		v := version.Add(1)
+		if v > 1 {
+			refreshed <- struct{}{}
+		}
+		return v, nil
+	}, Options{TTL: time.Minute, Stale: time.Minute})
+
+	ctx := context.Background()
+	if v, _ := c.Get(ctx, "key"); v != 1 {
+		t.Fatalf("first Get = %d, want 1", v)
+	}
+
+	// Stale: the old value comes back at once and a refresh starts.
+	clock.Advance(90 * time.Second)
+	if v, _ := c.Get(ctx, "key"); v != 1 {
+		t.Fatalf("stale Get = %d, want 1", v)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale value was not refreshed in the background")
+	}
+
+	// The refresh stores its result before it is marked done, so wait
+	// until Get sees the new value.
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := c.Get(ctx, "key"); v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed value never served")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Past the stale window Get waits for a new load.
+	clock.Advance(3 * time.Minute)
+	if v, _ := c.Get(ctx, "key"); v != 3 {
+		t.Errorf("expired Get = %d, want 3", v)
+	}
+}
+
+func TestErrorsAreCached(t *testing.T) {
+	errDown := errors.New("backend down")
+	var calls atomic.Int32
+
+	c, clock := newTestCache(func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		return "", errDown
+	}, Options{TTL: time.Minute, ErrorTTL: 5 * time.Second})
+
+	ctx := context.Background()
+	for range 3 {
+		if _, err := c.Get(ctx, "key"); !errors.Is(err, errDown) {
+			t.Fatalf("Get error = %v, want %v", err, errDown)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times within ErrorTTL, want 1", n)
+	}
+
+	clock.Advance(6 * time.Second)
+	c.Get(ctx, "key")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader ran %d times after ErrorTTL, want 2", n)
+	}
+}
+
+func TestErrorsNotCachedWithoutErrorTTL(t *testing.T) {
+	var calls atomic.Int32
+	c, _ := newTestCache(func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		return "", errors.New("fail")
+	}, Options{TTL: time.Minute})
+
+	for range 3 {
+		c.Get(context.Background(), "key")
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("loader ran %d times, want 3", n)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len = %d, want 0", n)
+	}
+}
+
+// waitForRefresh waits until no background refresh of key is running.
+func waitForRefresh[K comparable, V any](t *testing.T, c *Cache[K, V], key K) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		el, ok := c.items[key]
+		refreshing := ok && el.Value.(*entry[K, V]).refreshing
+		c.mu.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("refresh did not finish")
+}
+
+func TestFailedRefreshKeepsStaleValue(t *testing.T) {
+	var fail atomic.Bool
+	var calls atomic.Int32
+
+	c, clock := newTestCache(func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		if fail.Load() {
+			return "", errors.New("fail")
+		}
+		return "good", nil
+	}, Options{TTL: time.Minute, Stale: 10 * time.Minute, ErrorTTL: time.Minute})
+
+	ctx := context.Background()
+	c.Get(ctx, "key")
+
+	fail.Store(true)
+	clock.Advance(90 * time.Second)
+	c.Get(ctx, "key")
+	waitForRefresh(t, c, "key")
+
+	// Within ErrorTTL of the failure the stale value is served without
+	// asking the failing backend again.
+	for range 5 {
+		clock.Advance(5 * time.Second)
+		if v, err := c.Get(ctx, "key"); v != "good" || err != nil {
+			t.Errorf("Get after failed refresh = %q, %v, want the stale value", v, err)
+		}
+		waitForRefresh(t, c, "key")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader ran %d times before ErrorTTL passed, want 2", n)
+	}
+
+	clock.Advance(time.Minute)
+	c.Get(ctx, "key")
+	waitForRefresh(t, c, "key")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("loader ran %d times after ErrorTTL passed, want 3", n)
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	var calls atomic.Int32
+	c, _ := newTestCache(func(ctx context.Context, key int) (int, error) {
+		calls.Add(1)
+		return key * 10, nil
+	}, Options{TTL: time.Minute, MaxEntries: 2})
+
+	ctx := context.Background()
+	c.Get(ctx, 1)
+	c.Get(ctx, 2)
+	c.Get(ctx, 1) // 1 is now the most recently used
+	c.Get(ctx, 3) // evicts 2
+
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("loader ran %d times, want 3", n)
+	}
+
+	c.Get(ctx, 1)
+	if n := calls.Load(); n != 3 {
+		t.Errorf("recently used key 1 was evicted")
+	}
+	c.Get(ctx, 2)
+	if n := calls.Load(); n != 4 {
+		t.Errorf("least recently used key 2 was not evicted")
+	}
+}
+
+func TestLoaderPanicBecomesError(t *testing.T) {
+	c, _ := newTestCache(func(ctx context.Context, key string) (string, error) {
+		panic("boom")
+	}, Options{TTL: time.Minute})
+
+	_, err := c.Get(context.Background(), "key")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Get error = %v, want the panic value", err)
+	}
+}
+
+func TestCancelledGetKeepsLoadRunning(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	c, _ := newTestCache(func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}, Options{TTL: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "key")
+		got <- err
+	}()
+
+	// Wait for the load to start, then give up on it.
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-got; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Get = %v, want context.Canceled", err)
+	}
+
+	// A second caller joins the same load and gets its result.
+	close(release)
+	if v, err := c.Get(context.Background(), "key"); v != "value" || err != nil {
+		t.Errorf("Get = %q, %v, want value", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+}
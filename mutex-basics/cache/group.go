@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// group makes sure only one call for a key runs at a time. Callers that
+// arrive while a call is running wait for it and share its result. It
+// works like golang.org/x/sync/singleflight, but with typed keys and
+// values and context-aware waiting.
+type group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// do runs fn for key unless a call for key is already running, then
+// waits for the result. fn runs in its own goroutine, so a caller whose
+// ctx is cancelled stops waiting without cancelling the shared call.
+func (g *group[K, V]) do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &call[V]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *group[K, V]) run(key K, c *call[V], fn func() (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("cache: loader panicked: %v", r)
+		}
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mutex-basics/cache"
+)
+
+// runCacheDemo sends 1000 concurrent requests for a key that is not
+// cached yet and shows that the slow loader only runs once.
+func runCacheDemo() {
+	var calls atomic.Int64
+
+	users := cache.New(func(ctx context.Context, id int) (string, error) {
+		calls.Add(1)
+		if id < 0 {
+			return "", errors.New("invalid user id")
+		}
+
+		// Pretend this is a slow database query.
+		time.Sleep(100 * time.Millisecond)
+		return fmt.Sprintf("user-%d", id), nil
+	}, cache.Options{
+		TTL:        time.Minute,
+		Stale:      time.Minute,
+		ErrorTTL:   5 * time.Second,
+		MaxEntries: 100,
+	})
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users.Get(ctx, 1)
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println("Requests: 1000, loader calls:", calls.Load())
+
+	// Errors are cached too, so a bad key does not reach the loader
+	// on every request.
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		_, err := users.Get(ctx, -1)
+		fmt.Println("Error:", err)
+	}
+	fmt.Println("Requests: 3, loader calls:", calls.Load())
+}
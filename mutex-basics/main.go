@@ -1,11 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"sync"
 )
 
 func main() {
+	cacheDemo := flag.Bool("cache", false, "show a cache sharing one load between many requests")
+	flag.Parse()
+
+	if *cacheDemo {
+		runCacheDemo()
+		return
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
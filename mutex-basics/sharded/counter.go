@@ -0,0 +1,55 @@
+package sharded
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// Counter is an integer counter for heavy concurrent writes. Each Add
+// goes to a randomly chosen shard, so goroutines rarely update the same
+// memory. Reading the value sums every shard, which makes Value slower
+// than Add.
+type Counter struct {
+	shards []counterShard
+}
+
+// counterShard is padded to a 64-byte cache line so that neighbouring
+// shards do not slow each other down.
+type counterShard struct {
+	n atomic.Int64
+	_ [56]byte
+}
+
+// NewCounter returns a counter with at least the given number of
+// shards. The count is rounded up to a power of two.
+func NewCounter(shards int) *Counter {
+	return &Counter{shards: make([]counterShard, powerOfTwo(shards))}
+}
+
+// Add adds delta to the counter.
+func (c *Counter) Add(delta int64) {
+	i := rand.Uint64() & uint64(len(c.shards)-1)
+	c.shards[i].n.Add(delta)
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the sum of all shards. Adds that happen during the call
+// may or may not be included.
+func (c *Counter) Value() int64 {
+	var total int64
+	for i := range c.shards {
+		total += c.shards[i].n.Load()
+	}
+	return total
+}
+
+// Reset sets the counter back to zero.
+func (c *Counter) Reset() {
+	for i := range c.shards {
+		c.shards[i].n.Store(0)
+	}
+}
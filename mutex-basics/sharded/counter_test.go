@@ -0,0 +1,63 @@
+package sharded
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCounterValue(t *testing.T) {
+	c := NewCounter(8)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			for range 1000 {
+				c.Inc()
+			}
+			c.Add(-10)
+		})
+	}
+	wg.Wait()
+
+	if got, want := c.Value(), int64(50*1000-50*10); got != want {
+		t.Errorf("Value = %d, want %d", got, want)
+	}
+
+	c.Reset()
+	if got := c.Value(); got != 0 {
+		t.Errorf("Value after Reset = %d, want 0", got)
+	}
+}
+
+// BenchmarkCounter compares the sharded counter with a single mutex and
+// a single atomic, with every CPU incrementing at once.
+func BenchmarkCounter(b *testing.B) {
+	var mu sync.Mutex
+	var plain int64
+	var single atomic.Int64
+	sharded := NewCounter(0)
+
+	counters := []struct {
+		name string
+		inc  func()
+	}{
+		{"Mutex", func() {
+			mu.Lock()
+			plain++
+			mu.Unlock()
+		}},
+		{"Atomic", func() { single.Add(1) }},
+		{"Sharded", sharded.Inc},
+	}
+
+	for _, c := range counters {
+		b.Run(c.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.inc()
+				}
+			})
+		})
+	}
+}
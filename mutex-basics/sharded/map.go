@@ -0,0 +1,155 @@
+// Package sharded provides a map and a counter that spread their state
+// across several shards, so goroutines working on different keys do not
+// wait on the same lock.
+package sharded
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// DefaultShards is used when a constructor is given a shard count below
+// one.
+const DefaultShards = 32
+
+// ConcurrentMap is a map safe for concurrent use. Keys are hashed to one
+// of several shards, each guarded by its own sync.RWMutex, so writers
+// only block goroutines that use the same shard and readers never block
+// each other.
+type ConcurrentMap[K comparable, V any] struct {
+	seed   maphash.Seed
+	mask   uint64
+	shards []mapShard[K, V]
+}
+
+type mapShard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// NewConcurrentMap returns an empty map with at least the given number
+// of shards. The count is rounded up to a power of two.
+func NewConcurrentMap[K comparable, V any](shards int) *ConcurrentMap[K, V] {
+	n := powerOfTwo(shards)
+
+	c := &ConcurrentMap[K, V]{
+		seed:   maphash.MakeSeed(),
+		mask:   uint64(n - 1),
+		shards: make([]mapShard[K, V], n),
+	}
+	for i := range c.shards {
+		c.shards[i].m = make(map[K]V)
+	}
+	return c
+}
+
+func (c *ConcurrentMap[K, V]) shard(key K) *mapShard[K, V] {
+	return &c.shards[maphash.Comparable(c.seed, key)&c.mask]
+}
+
+// Load returns the value stored for key, if any.
+func (c *ConcurrentMap[K, V]) Load(key K) (V, bool) {
+	s := c.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.m[key]
+	return v, ok
+}
+
+// Store sets the value for key.
+func (c *ConcurrentMap[K, V]) Store(key K, value V) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m[key] = value
+}
+
+// Delete removes key from the map.
+func (c *ConcurrentMap[K, V]) Delete(key K) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, key)
+}
+
+// ComputeIfAbsent returns the value for key, calling create to make and
+// store one if the key is missing. create runs at most once per missing
+// key, even when many goroutines ask for it together. loaded reports
+// whether the value was already present.
+//
+// create runs while the key's shard is locked, so it must not use the
+// map itself.
+func (c *ConcurrentMap[K, V]) ComputeIfAbsent(key K, create func(K) V) (value V, loaded bool) {
+	s := c.shard(key)
+
+	// Most calls find the key, so try the cheap read lock first.
+	s.mu.RLock()
+	v, ok := s.m[key]
+	s.mu.RUnlock()
+	if ok {
+		return v, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another goroutine may have stored the key between the two locks.
+	if v, ok := s.m[key]; ok {
+		return v, true
+	}
+	v = create(key)
+	s.m[key] = v
+	return v, false
+}
+
+// Len returns the number of keys. Shards are counted one at a time, so
+// the result may be out of date if other goroutines are writing.
+func (c *ConcurrentMap[K, V]) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls fn for each key and value until fn returns false. Each
+// shard is copied before fn is called, so fn may safely use the map.
+func (c *ConcurrentMap[K, V]) Range(fn func(K, V) bool) {
+	type entry struct {
+		key   K
+		value V
+	}
+
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		entries := make([]entry, 0, len(s.m))
+		for k, v := range s.m {
+			entries = append(entries, entry{k, v})
+		}
+		s.mu.RUnlock()
+
+		for _, e := range entries {
+			if !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+func powerOfTwo(n int) int {
+	if n < 1 {
+		n = DefaultShards
+	}
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,193 @@
+package sharded
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	m := NewConcurrentMap[string, int](4)
+	m.Store("a", 1)
+	m.Store("b", 2)
+	m.Store("a", 3)
+
+	if v, ok := m.Load("a"); !ok || v != 3 {
+		t.Errorf("Load(a) = %d, %t, want 3, true", v, ok)
+	}
+	if n := m.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+
+	m.Delete("a")
+	if _, ok := m.Load("a"); ok {
+		t.Error("a still present after Delete")
+	}
+
+	seen := map[string]int{}
+	m.Range(func(k string, v int) bool {
+		seen[k] = v
+		return true
+	})
+	if len(seen) != 1 || seen["b"] != 2 {
+		t.Errorf("Range saw %v, want map[b:2]", seen)
+	}
+}
+
+func TestComputeIfAbsentCreatesOnce(t *testing.T) {
+	m := NewConcurrentMap[string, int](0)
+
+	var calls atomic.Int32
+	var loadedCount atomic.Int32
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() {
+			<-start
+			v, loaded := m.ComputeIfAbsent("key", func(string) int {
+				calls.Add(1)
+				return 42
+			})
+			if v != 42 {
+				t.Errorf("ComputeIfAbsent = %d, want 42", v)
+			}
+			if loaded {
+				loadedCount.Add(1)
+			}
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("create ran %d times, want 1", n)
+	}
+	if n := loadedCount.Load(); n != 99 {
+		t.Errorf("loaded = true for %d callers, want 99", n)
+	}
+}
+
+func TestShardCountRoundsUp(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, DefaultShards},
+		{-1, DefaultShards},
+		{1, 1},
+		{5, 8},
+		{16, 16},
+	}
+	for _, tt := range tests {
+		if got := len(NewConcurrentMap[int, int](tt.in).shards); got != tt.want {
+			t.Errorf("NewConcurrentMap(%d) has %d shards, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// benchKeys is the number of distinct keys the map benchmarks use.
+const benchKeys = 1024
+
+// stringMap is the part of each map implementation the benchmarks use.
+type stringMap interface {
+	Load(key int) (string, bool)
+	Store(key int, value string)
+}
+
+// mutexMap guards a whole map with one sync.Mutex, as the lesson does
+// for its counter.
+type mutexMap struct {
+	mu sync.Mutex
+	m  map[int]string
+}
+
+func (m *mutexMap) Load(key int) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m[key]
+	return v, ok
+}
+
+func (m *mutexMap) Store(key int, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m[key] = value
+}
+
+// rwMutexMap lets readers share the lock.
+type rwMutexMap struct {
+	mu sync.RWMutex
+	m  map[int]string
+}
+
+func (m *rwMutexMap) Load(key int) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.m[key]
+	return v, ok
+}
+
+func (m *rwMutexMap) Store(key int, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m[key] = value
+}
+
+// syncMap adapts sync.Map to stringMap.
+type syncMap struct {
+	m sync.Map
+}
+
+func (m *syncMap) Load(key int) (string, bool) {
+	v, ok := m.m.Load(key)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+func (m *syncMap) Store(key int, value string) {
+	m.m.Store(key, value)
+}
+
+var benchMaps = []struct {
+	name string
+	make func() stringMap
+}{
+	{"Mutex", func() stringMap { return &mutexMap{m: make(map[int]string)} }},
+	{"RWMutex", func() stringMap { return &rwMutexMap{m: make(map[int]string)} }},
+	{"SyncMap", func() stringMap { return &syncMap{} }},
+	{"ConcurrentMap", func() stringMap { return NewConcurrentMap[int, string](0) }},
+}
+
+// benchMap measures each map when readPercent of operations are reads
+// and the rest are writes, with every CPU running operations at once.
+func benchMap(b *testing.B, readPercent int) {
+	for _, bm := range benchMaps {
+		b.Run(bm.name, func(b *testing.B) {
+			m := bm.make()
+			for i := range benchKeys {
+				m.Store(i, "value")
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					key := rand.IntN(benchKeys)
+					if rand.IntN(100) < readPercent {
+						m.Load(key)
+					} else {
+						m.Store(key, "value")
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMapReadHeavy(b *testing.B) {
+	benchMap(b, 90)
+}
+
+func BenchmarkMapWriteHeavy(b *testing.B) {
+	benchMap(b, 10)
+}
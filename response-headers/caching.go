@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etag builds an entity tag from the response body. A weak tag says the
+// body is equivalent rather than byte-for-byte identical.
+func etag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagMatch reports whether any tag in an If-None-Match header matches
+// tag using weak comparison, where W/"x" and "x" are equal.
+func etagMatch(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether a 304 can be sent instead of the body.
+// If-None-Match takes precedence: when it is present, If-Modified-Since
+// is ignored.
+func notModified(r *http.Request, tag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, tag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP dates only have second precision.
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// cacheControl builds a Cache-Control header value.
+//
+// MaxAge is a pointer so that max-age=0 can be told apart from no
+// max-age at all. A response is either shared or per user, so Private
+// wins when both Public and Private are set.
+type cacheControl struct {
+	Public               bool
+	Private              bool
+	NoCache              bool
+	NoStore              bool
+	MustRevalidate       bool
+	Immutable            bool
+	MaxAge               *time.Duration
+	StaleWhileRevalidate time.Duration
+}
+
+func (cc cacheControl) String() string {
+	if cc.NoStore {
+		return "no-store"
+	}
+
+	var directives []string
+	switch {
+	case cc.Private:
+		directives = append(directives, "private")
+	case cc.Public:
+		directives = append(directives, "public")
+	}
+	if cc.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if cc.MaxAge != nil {
+		directives = append(directives, "max-age="+strconv.Itoa(max(0, int(cc.MaxAge.Seconds()))))
+	}
+	if cc.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+strconv.Itoa(int(cc.StaleWhileRevalidate.Seconds())))
+	}
+	if cc.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if cc.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// writeCached writes body with ETag, Last-Modified and Cache-Control
+// headers, or a 304 Not Modified when the client's copy is current.
+func writeCached(w http.ResponseWriter, r *http.Request, body []byte, modified time.Time, policy cacheControl) {
+	tag := etag(body, false)
+
+	h := w.Header()
+	h.Set("ETag", tag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if cc := policy.String(); cc != "" {
+		h.Set("Cache-Control", cc)
+	}
+
+	if notModified(r, tag, modified) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tag := etag([]byte("body"), false)
+	weak := "W/" + tag
+	other := etag([]byte("other"), false)
+
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", "GET", nil, false},
+		{"strong match", "GET", map[string]string{"If-None-Match": tag}, true},
+		{"weak header matches strong tag", "GET", map[string]string{"If-None-Match": weak}, true},
+		{"match in list", "GET", map[string]string{"If-None-Match": other + ", " + weak}, true},
+		{"star", "GET", map[string]string{"If-None-Match": "*"}, true},
+		{"no match", "GET", map[string]string{"If-None-Match": other}, false},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": before}, false},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": after}, true},
+		{"same second", "GET", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		{"bad date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{
+			"If-None-Match mismatch beats fresh If-Modified-Since", "GET",
+			map[string]string{"If-None-Match": other, "If-Modified-Since": after},
+			false,
+		},
+		{
+			"If-None-Match match beats stale If-Modified-Since", "GET",
+			map[string]string{"If-None-Match": tag, "If-Modified-Since": before},
+			true,
+		},
+		{"HEAD", "HEAD", map[string]string{"If-None-Match": tag}, true},
+		{"POST never 304", "POST", map[string]string{"If-None-Match": tag}, false},
+		{"PUT never 304", "PUT", map[string]string{"If-Modified-Since": after}, false},
+		{"DELETE never 304", "DELETE", map[string]string{"If-None-Match": "*"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/article", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := notModified(r, tag, modified); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestETagWeakAndStrongCompareEqual(t *testing.T) {
+	body := []byte("body")
+	strong, weak := etag(body, false), etag(body, true)
+
+	if weak != "W/"+strong {
+		t.Fatalf("weak = %s, strong = %s", weak, strong)
+	}
+	for _, pair := range [][2]string{{strong, weak}, {weak, strong}, {weak, weak}} {
+		if !etagMatch(pair[0], pair[1]) {
+			t.Errorf("etagMatch(%s, %s) = false", pair[0], pair[1])
+		}
+	}
+}
+
+func TestWriteCached(t *testing.T) {
+	body := []byte("hello\n")
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := cacheControl{Public: true, MaxAge: new(time.Hour)}
+
+	rec := httptest.NewRecorder()
+	writeCached(rec, httptest.NewRequest("GET", "/", nil), body, modified, policy)
+	if rec.Code != http.StatusOK || rec.Body.String() != string(body) {
+		t.Fatalf("first response: %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	writeCached(rec, req, body, modified, policy)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("revalidation: %d %q", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") == "" || rec.Header().Get("Cache-Control") == "" {
+		t.Error("304 should repeat ETag and Cache-Control")
+	}
+}
+
+func TestCacheControlString(t *testing.T) {
+	tests := []struct {
+		cc   cacheControl
+		want string
+	}{
+		{cacheControl{}, ""},
+		{cacheControl{NoStore: true, Public: true}, "no-store"},
+		{cacheControl{Private: true, NoCache: true}, "private, no-cache"},
+		{cacheControl{Public: true, MaxAge: new(time.Minute), StaleWhileRevalidate: 30 * time.Second}, "public, max-age=60, stale-while-revalidate=30"},
+		{cacheControl{MaxAge: new(time.Hour), MustRevalidate: true, Immutable: true}, "max-age=3600, must-revalidate, immutable"},
+		{cacheControl{Public: true, MaxAge: new(time.Duration(0)), MustRevalidate: true}, "public, max-age=0, must-revalidate"},
+		{cacheControl{Public: true, Private: true, MaxAge: new(time.Minute)}, "private, max-age=60"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cc.String(); got != tt.want {
+			t.Errorf("%+v = %q, want %q", tt.cc, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 func main() {
@@ -11,5 +12,16 @@ func main() {
 		w.WriteHeader(http.StatusCreated)
 		fmt.Fprintln(w, "Some content")
 	})
+
+	published := time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC)
+	http.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		writeCached(w, r, []byte("An article that rarely changes\n"), published, cacheControl{
+			Public:         true,
+			MaxAge:         new(time.Hour),
+			MustRevalidate: true,
+		})
+	})
+
 	http.ListenAndServe(":8080", nil)
 }
@@ -1,14 +1,50 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-func main() {
-	http.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+func routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "Hello from the server")
 	})
 
-	http.ListenAndServe(":8080", nil)
+	return mux
+}
+
+func main() {
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Println("Invalid configuration:", err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", cfg.addr)
+	if err != nil {
+		log.Println("Failed to listen:", err)
+		os.Exit(1)
+	}
+
+	limiter := newRateLimiter(cfg.rateLimit, cfg.rateBurst, cfg.trustedProxies)
+	srv := newServer(cfg, limiter.middleware(routes()))
+
+	log.Println("Listening on", ln.Addr())
+	err = serve(ctx, srv, ln, cfg.shutdownTimeout)
+	if err != nil {
+		log.Println("Server error:", err)
+		os.Exit(1)
+	}
+	log.Println("Server stopped")
 }
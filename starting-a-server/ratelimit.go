@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a token bucket per client. Each client can make burst
+// requests at once, refilled at rate requests per second.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	rate    float64
+	burst   int
+
+	// idleTTL is how long an untouched bucket is kept. A bucket idle for
+	// that long would be full again anyway, so dropping it is safe.
+	idleTTL   time.Duration
+	lastSweep time.Time
+
+	trusted []netip.Prefix
+	now     func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int, trusted []netip.Prefix) *rateLimiter {
+	idle := time.Minute
+	if refill := time.Duration(float64(burst) / rate * float64(time.Second)); refill > idle {
+		idle = refill
+	}
+
+	return &rateLimiter{
+		buckets: make(map[string]*bucket),
+		rate:    rate,
+		burst:   burst,
+		idleTTL: idle,
+		trusted: trusted,
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from key's bucket. It returns whether the request
+// may proceed, the tokens left, and how long until a token is available.
+func (rl *rateLimiter) allow(key string) (bool, int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rl.burst), lastSeen: now}
+		rl.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(rl.burst), b.tokens+elapsed*rl.rate)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+		return false, 0, wait
+	}
+
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// sweep removes idle buckets at most once per idleTTL. The caller must
+// hold rl.mu.
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.idleTTL {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) >= rl.idleTTL {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// believed when the request came from a trusted proxy, and is read from
+// right to left so a client cannot spoof it by adding entries.
+func (rl *rateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !rl.isTrusted(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !rl.isTrusted(hop) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func (rl *rateLimiter) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range rl.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware rejects requests over the limit with 429 Too Many Requests
+// and reports the client's quota in RateLimit-* headers.
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, remaining, wait := rl.allow(rl.clientIP(r))
+
+		reset := int(math.Ceil(wait.Seconds()))
+		if ok {
+			reset = int(math.Ceil(float64(rl.burst-remaining) / rl.rate))
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(rl.burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !ok {
+			h.Set("Retry-After", strconv.Itoa(reset))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parsePrefixes parses a comma-separated list of IP addresses or CIDR
+// ranges.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time source that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, rate float64, burst int, trusted string) (*rateLimiter, *fakeClock) {
+	t.Helper()
+
+	prefixes, err := parsePrefixes(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rl := newRateLimiter(rate, burst, prefixes)
+	rl.now = clock.now
+	return rl, clock
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl, clock := newTestLimiter(t, 2, 3, "")
+
+	for i := range 3 {
+		ok, remaining, _ := rl.allow("a")
+		if !ok || remaining != 2-i {
+			t.Fatalf("request %d: ok=%v remaining=%d", i, ok, remaining)
+		}
+	}
+
+	ok, _, wait := rl.allow("a")
+	if ok {
+		t.Fatal("fourth request should be limited")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms at 2 tokens per second", wait)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _, _ := rl.allow("b"); !ok {
+		t.Error("client b should not share a's bucket")
+	}
+
+	clock.advance(500 * time.Millisecond)
+	if ok, _, _ := rl.allow("a"); !ok {
+		t.Error("one token should have refilled after 500ms")
+	}
+	if ok, _, _ := rl.allow("a"); ok {
+		t.Error("only one token should have refilled")
+	}
+
+	// Refilling never goes past burst.
+	clock.advance(time.Hour)
+	for i := range 4 {
+		ok, _, _ := rl.allow("a")
+		if ok != (i < 3) {
+			t.Errorf("after long idle, request %d: ok=%v", i, ok)
+		}
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	rl, clock := newTestLimiter(t, 0.5, 2, "")
+	h := rl.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/home", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 2 {
+		if rec := send(); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %q, want 2", got)
+	}
+
+	clock.advance(2 * time.Second)
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Errorf("after Retry-After: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	rl, clock := newTestLimiter(t, 1, 5, "")
+
+	rl.allow("a")
+	clock.advance(rl.idleTTL / 2)
+	rl.allow("b")
+
+	clock.advance(rl.idleTTL / 2)
+	rl.allow("c")
+
+	if _, ok := rl.buckets["a"]; ok {
+		t.Error("idle bucket a should have been removed")
+	}
+	if _, ok := rl.buckets["b"]; !ok {
+		t.Error("bucket b is not idle yet and should be kept")
+	}
+	if len(rl.buckets) != 2 {
+		t.Errorf("buckets = %d, want 2", len(rl.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted string
+		remote  string
+		xff     []string
+		want    string
+	}{
+		{"no proxy", "", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"untrusted proxy header ignored", "", "192.0.2.1:1234", []string{"203.0.113.9"}, "192.0.2.1"},
+		{"trusted proxy", "10.0.0.0/8", "10.0.0.1:1234", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"spoofed entry on the left", "10.0.0.0/8", "10.0.0.1:1234", []string{"1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"chain of trusted proxies", "10.0.0.0/8", "10.0.0.1:1234", []string{"203.0.113.9, 10.0.0.2"}, "203.0.113.9"},
+		{"split across headers", "10.0.0.0/8", "10.0.0.1:1234", []string{"203.0.113.9", "10.0.0.2"}, "203.0.113.9"},
+		{"garbage stops the walk", "10.0.0.0/8", "10.0.0.1:1234", []string{"203.0.113.9, nonsense"}, "10.0.0.1"},
+		{"all trusted", "10.0.0.0/8", "10.0.0.1:1234", []string{"10.0.0.3"}, "10.0.0.3"},
+		{"single trusted address", "10.0.0.1", "10.0.0.1:1234", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"IPv6", "::1", "[::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, _ := newTestLimiter(t, 1, 1, tt.trusted)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := rl.clientIP(req); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"time"
+)
+
+type config struct {
+	addr            string
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	shutdownTimeout time.Duration
+	rateLimit       float64
+	rateBurst       int
+	trustedProxies  []netip.Prefix
+}
+
+// loadConfig reads settings from flags, falling back to environment
+// variables and then to sensible defaults.
+func loadConfig(args []string) (config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+
+	var cfg config
+	var env envReader
+	fs.StringVar(&cfg.addr, "addr", env.string("ADDR", ":8080"), "address to listen on")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", env.duration("READ_TIMEOUT", 5*time.Second), "maximum time to read a request")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", env.duration("WRITE_TIMEOUT", 10*time.Second), "maximum time to write a response")
+	fs.DurationVar(&cfg.idleTimeout, "idle-timeout", env.duration("IDLE_TIMEOUT", time.Minute), "maximum time to keep idle connections open")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", env.duration("SHUTDOWN_TIMEOUT", 15*time.Second), "maximum time to drain requests on shutdown")
+
+	fs.Float64Var(&cfg.rateLimit, "rate-limit", env.float("RATE_LIMIT", 5), "requests per second allowed per client")
+	fs.IntVar(&cfg.rateBurst, "rate-burst", env.int("RATE_BURST", 10), "requests a client may make in a burst")
+	proxies := fs.String("trusted-proxies", env.string("TRUSTED_PROXIES", ""), "comma-separated proxy IPs or CIDRs whose X-Forwarded-For is trusted")
+
+	if env.err != nil {
+		return cfg, env.err
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	// NaN fails every comparison, so it would pass the <= 0 check and
+	// then stop the bucket from ever refilling. +Inf turns limiting off.
+	if cfg.rateLimit <= 0 || math.IsNaN(cfg.rateLimit) || math.IsInf(cfg.rateLimit, 0) || cfg.rateBurst < 1 {
+		return cfg, errors.New("rate-limit must be a positive finite number and rate-burst must be positive")
+	}
+
+	cfg.trustedProxies, err = parsePrefixes(*proxies)
+	if err != nil {
+		return cfg, fmt.Errorf("trusted-proxies: %w", err)
+	}
+	return cfg, nil
+}
+
+// envReader reads settings from environment variables. It keeps the
+// first malformed value it sees in err, so a typo such as
+// READ_TIMEOUT=abc is reported instead of silently replaced by the
+// default.
+type envReader struct {
+	err error
+}
+
+func (e *envReader) string(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func (e *envReader) float(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.fail(key, value)
+		return fallback
+	}
+	return f
+}
+
+func (e *envReader) int(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.fail(key, value)
+		return fallback
+	}
+	return n
+}
+
+func (e *envReader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.fail(key, value)
+		return fallback
+	}
+	return d
+}
+
+func (e *envReader) fail(key, value string) {
+	if e.err == nil {
+		e.err = fmt.Errorf("invalid value %q for %s", value, key)
+	}
+}
+
+func newServer(cfg config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         cfg.addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.readTimeout,
+		WriteTimeout: cfg.writeTimeout,
+		IdleTimeout:  cfg.idleTimeout,
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then gives in-flight
+// requests up to drain to finish before returning.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, drain time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if serveErr := <-errCh; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	home := routes()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		home.ServeHTTP(w, r)
+	})
+
+	ts := httptest.NewUnstartedServer(handler)
+	ln, srv := ts.Listener, ts.Config
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, srv, ln, 5*time.Second)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/home")
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		resCh <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	// Wait until the server stops accepting connections, so the request
+	// is known to be draining rather than finishing before shutdown.
+	for {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			break
+		}
+		conn.Close()
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+
+	res := <-resCh
+	if res.err != nil {
+		t.Fatalf("in-flight request failed: %v", res.err)
+	}
+	if res.body != "Hello from the server\n" {
+		t.Errorf("body = %q", res.body)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("serve returned %v, want nil", err)
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ts := httptest.NewUnstartedServer(handler)
+	ln, srv := ts.Listener, ts.Config
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, srv, ln, 50*time.Millisecond)
+	}()
+
+	go http.Get("http://" + ln.Addr().String() + "/")
+	<-started
+	cancel()
+
+	if err := <-serveErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("serve returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", nil, nil, 5 * time.Second, false},
+		{"env", map[string]string{"READ_TIMEOUT": "2s"}, nil, 2 * time.Second, false},
+		{"flag beats env", map[string]string{"READ_TIMEOUT": "2s"}, []string{"-read-timeout=3s"}, 3 * time.Second, false},
+		{"malformed env", map[string]string{"READ_TIMEOUT": "abc"}, nil, 0, true},
+		{"malformed int env", map[string]string{"RATE_BURST": "ten"}, nil, 0, true},
+		{"malformed flag", nil, []string{"-read-timeout=abc"}, 0, true},
+		{"zero rate", nil, []string{"-rate-limit=0"}, 0, true},
+		{"NaN rate flag", nil, []string{"-rate-limit=NaN"}, 0, true},
+		{"NaN rate env", map[string]string{"RATE_LIMIT": "NaN"}, nil, 0, true},
+		{"infinite rate flag", nil, []string{"-rate-limit=+Inf"}, 0, true},
+		{"infinite rate env", map[string]string{"RATE_LIMIT": "Inf"}, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := loadConfig(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.readTimeout != tt.want {
+				t.Errorf("readTimeout = %v, want %v", cfg.readTimeout, tt.want)
+			}
+		})
+	}
+}
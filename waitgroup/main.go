@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+
+	"waitgroup/pool"
 )
 
 func main() {
@@ -20,4 +24,29 @@ func main() {
 	}
 
 	wg.Wait()
+
+	// The same work on a bounded pool of two workers, with results
+	// returned in input order.
+	squares, err := pool.Map(context.Background(), 2, numbers, func(ctx context.Context, n int) (int, error) {
+		return n * n, nil
+	})
+	fmt.Println("Squares:", squares, err)
+
+	// The first error cancels the rest of the work.
+	_, err = pool.Map(context.Background(), 2, numbers, func(ctx context.Context, n int) (int, error) {
+		if n == 3 {
+			return 0, errors.New("cannot process 3")
+		}
+		return n, nil
+	})
+	fmt.Println("Error:", err)
+
+	// A panic in a task becomes an error instead of crashing.
+	_, err = pool.Map(context.Background(), 2, numbers, func(ctx context.Context, n int) (int, error) {
+		var m map[int]int
+		m[n] = n
+		return n, nil
+	})
+	var panicErr *pool.PanicError
+	fmt.Println("Panic captured:", errors.As(err, &panicErr))
 }
@@ -0,0 +1,228 @@
+// Package pool runs work on a fixed number of goroutines.
+//
+// Submitting blocks while every worker is busy, so a fast producer
+// cannot queue unbounded work. The first error cancels the pool's
+// context so remaining work can stop early, and a panic in a task is
+// returned as a *PanicError instead of crashing the program.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrClosed is returned by Submit after Close has been called.
+var ErrClosed = errors.New("pool: closed")
+
+// PanicError is returned when a task panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pool: task panicked: %v", e.Value)
+}
+
+// Result is the outcome of one task. Index is the order in which the
+// task was submitted, starting at 0.
+type Result[R any] struct {
+	Index int
+	Value R
+	Err   error
+}
+
+type job[T any] struct {
+	index int
+	value T
+}
+
+// Pool runs fn on submitted values using a fixed number of workers.
+// Results arrive on Results in completion order; the caller must keep
+// reading them until the channel is closed.
+type Pool[T, R any] struct {
+	fn      func(context.Context, T) (R, error)
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	jobs    chan job[T]
+	results chan Result[R]
+	workers sync.WaitGroup
+
+	// done is closed by Close to wake Submit calls blocked on jobs.
+	// jobs itself is closed only once they have all returned.
+	done    chan struct{}
+	sending sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	next   int
+}
+
+// New starts a pool with the given number of workers. The pool's
+// context is derived from ctx and is cancelled by the first task error.
+func New[T, R any](ctx context.Context, workers int, fn func(context.Context, T) (R, error)) *Pool[T, R] {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	p := &Pool[T, R]{
+		fn:      fn,
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(chan job[T]),
+		results: make(chan Result[R], workers),
+		done:    make(chan struct{}),
+	}
+
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	go func() {
+		p.workers.Wait()
+		cancel(nil)
+		close(p.results)
+	}()
+
+	return p
+}
+
+func (p *Pool[T, R]) work() {
+	defer p.workers.Done()
+
+	for j := range p.jobs {
+		r := Result[R]{Index: j.index}
+		if err := context.Cause(p.ctx); err != nil {
+			r.Err = err
+		} else {
+			r.Value, r.Err = p.call(j.value)
+		}
+
+		if r.Err != nil {
+			p.cancel(r.Err)
+		}
+		p.results <- r
+	}
+}
+
+// call runs fn, turning a panic into a *PanicError.
+func (p *Pool[T, R]) call(v T) (result R, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = &PanicError{Value: rec, Stack: debug.Stack()}
+		}
+	}()
+	return p.fn(p.ctx, v)
+}
+
+// Submit hands v to the next free worker, blocking until one is
+// available. It returns an error if the pool is closed or its context
+// is done, including while it is waiting.
+func (p *Pool[T, R]) Submit(v T) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	if err := context.Cause(p.ctx); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	j := job[T]{index: p.next, value: v}
+	p.next++
+	p.sending.Add(1)
+	p.mu.Unlock()
+
+	defer p.sending.Done()
+
+	select {
+	case p.jobs <- j:
+		return nil
+	case <-p.done:
+		return ErrClosed
+	case <-p.ctx.Done():
+		return context.Cause(p.ctx)
+	}
+}
+
+// Close stops accepting work. Submit calls still waiting for a worker
+// return ErrClosed. Results is closed once every submitted task has
+// finished.
+func (p *Pool[T, R]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	p.sending.Wait()
+	close(p.jobs)
+}
+
+// Results returns the channel on which task results are delivered.
+func (p *Pool[T, R]) Results() <-chan Result[R] {
+	return p.results
+}
+
+// Map runs fn on every input using the given number of workers and
+// returns the results in input order. On failure it stops submitting
+// work and returns every error that occurred, joined with errors.Join.
+func Map[T, R any](ctx context.Context, workers int, inputs []T, fn func(context.Context, T) (R, error)) ([]R, error) {
+	p := New(ctx, workers, fn)
+
+	go func() {
+		defer p.Close()
+		for _, v := range inputs {
+			if p.Submit(v) != nil {
+				return
+			}
+		}
+	}()
+
+	out := make([]R, len(inputs))
+	var errs []error
+	for r := range p.Results() {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			continue
+		}
+		out[r.Index] = r.Value
+	}
+
+	err := joinErrors(errs, context.Cause(p.ctx))
+	if err == nil {
+		// Submission may have stopped early without any task failing.
+		err = ctx.Err()
+	}
+	return out, err
+}
+
+// joinErrors drops the noise caused by cancellation: tasks skipped or
+// interrupted because an earlier task failed report cause or
+// context.Canceled, and only the original failures are kept.
+func joinErrors(errs []error, cause error) error {
+	cancelledByTask := !errors.Is(cause, context.Canceled)
+
+	var kept []error
+	sawCause := false
+	for _, err := range errs {
+		if cancelledByTask && errors.Is(err, context.Canceled) {
+			continue
+		}
+		if errors.Is(err, cause) {
+			if sawCause {
+				continue
+			}
+			sawCause = true
+		}
+		kept = append(kept, err)
+	}
+	return errors.Join(kept...)
+}
@@ -0,0 +1,231 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapKeepsInputOrder(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	// Later inputs finish first, so completion order is reversed.
+	out, err := Map(context.Background(), 8, inputs, func(ctx context.Context, n int) (int, error) {
+		time.Sleep(time.Duration(100-n) * 10 * time.Microsecond)
+		return n * n, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range out {
+		if v != i*i {
+			t.Fatalf("out[%d] = %d, want %d", i, v, i*i)
+		}
+	}
+}
+
+func TestFirstErrorCancels(t *testing.T) {
+	errBoom := errors.New("boom")
+	var started atomic.Int32
+
+	inputs := make([]int, 1000)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	_, err := Map(context.Background(), 4, inputs, func(ctx context.Context, n int) (int, error) {
+		started.Add(1)
+		if n == 10 {
+			return 0, errBoom
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Millisecond):
+			return n, nil
+		}
+	})
+
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("cancellation noise was not filtered: %v", err)
+	}
+	if n := started.Load(); n >= 1000 {
+		t.Errorf("%d tasks started, want submission to stop after the error", n)
+	}
+}
+
+func TestParentContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Map(ctx, 2, []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) {
+		return n, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestPanicBecomesError(t *testing.T) {
+	_, err := Map(context.Background(), 2, []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) {
+		if n == 2 {
+			panic("bad input")
+		}
+		return n, nil
+	})
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, want a *PanicError", err)
+	}
+	if pe.Value != "bad input" || len(pe.Stack) == 0 {
+		t.Errorf("PanicError = %v with %d bytes of stack", pe.Value, len(pe.Stack))
+	}
+}
+
+func TestSubmitBlocksWhileWorkersBusy(t *testing.T) {
+	release := make(chan struct{})
+	p := New(context.Background(), 2, func(ctx context.Context, n int) (int, error) {
+		<-release
+		return n, nil
+	})
+
+	// Drain results in the background so workers never block on them.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range p.Results() {
+		}
+	}()
+
+	for i := range 2 {
+		if err := p.Submit(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	submitted := make(chan error)
+	go func() { submitted <- p.Submit(2) }()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned while every worker was busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+
+	p.Close()
+	wg.Wait()
+
+	if err := p.Submit(3); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseWakesBlockedSubmit(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := New(context.Background(), 1, func(ctx context.Context, n int) (int, error) {
+		<-release
+		return n, nil
+	})
+
+	if err := p.Submit(0); err != nil {
+		t.Fatal(err)
+	}
+
+	submitted := make(chan error)
+	go func() { submitted <- p.Submit(1) }()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind a waiting Submit")
+	}
+	if err := <-submitted; !errors.Is(err, ErrClosed) {
+		t.Errorf("blocked Submit = %v, want ErrClosed", err)
+	}
+}
+
+func TestNoGoroutinesLeft(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for range 10 {
+		Map(context.Background(), 4, []int{1, 2, 3, 4, 5}, func(ctx context.Context, n int) (int, error) {
+			if n == 3 {
+				return 0, errors.New("fail")
+			}
+			return n, nil
+		})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines: %d before, %d after", before, after)
+	}
+}
+
+// work is a small CPU-bound task for the benchmarks.
+func work(ctx context.Context, n int) (int, error) {
+	sum := 0
+	for i := range 1000 {
+		sum += i * n
+	}
+	return sum, nil
+}
+
+var benchInputs = func() []int {
+	inputs := make([]int, 10000)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	return inputs
+}()
+
+func BenchmarkMap(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		Map(context.Background(), runtime.GOMAXPROCS(0), benchInputs, work)
+	}
+}
+
+// BenchmarkGoroutinePerItem is the naive approach the pool replaces:
+// one goroutine per input, joined with a WaitGroup.
+func BenchmarkGoroutinePerItem(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		out := make([]int, len(benchInputs))
+		var wg sync.WaitGroup
+		for i, n := range benchInputs {
+			wg.Go(func() {
+				out[i], _ = work(context.Background(), n)
+			})
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	gzipPool = sync.Pool{New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}}
+	zlibPool = sync.Pool{New: func() any {
+		return zlib.NewWriter(io.Discard)
+	}}
+)
+
+// compressor is the part of gzip.Writer and zlib.Writer the middleware
+// needs. HTTP's "deflate" coding is the zlib format, not raw DEFLATE.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// alreadyCompressed lists content types that gain nothing from another
+// round of compression. Event streams are listed too: each event must
+// reach the client as soon as it is flushed, not when a compressed block
+// fills up.
+var alreadyCompressed = []string{
+	"text/event-stream",
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/pdf",
+}
+
+// compress returns middleware that gzip or deflate encodes responses
+// when the client allows it. Bodies smaller than minSize are sent as-is.
+func compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := chooseEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        minSize,
+				status:         http.StatusOK,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// chooseEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip when both are equally weighted. A q of 0 means the
+// client does not accept that encoding.
+func chooseEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				q = parsed
+			}
+		}
+
+		if name != "gzip" && name != "deflate" || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the first minSize bytes of the body. Once
+// that much has been written it decides whether to compress, so small
+// responses can still be sent uncompressed with a Content-Length.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     compressor
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+		err := cw.start(true)
+		return len(b), err
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// start sends the headers and any buffered bytes, compressing them if
+// allowed is true and the response is worth compressing. If it does not
+// compress, the rest of the body is passed through as-is.
+func (cw *compressWriter) start(allowed bool) error {
+	cw.decided = true
+	h := cw.Header()
+
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if allowed && cw.shouldCompress() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+
+		if cw.encoding == "gzip" {
+			cw.enc = gzipPool.Get().(*gzip.Writer)
+		} else {
+			cw.enc = zlibPool.Get().(*zlib.Writer)
+		}
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	if !bodyAllowed(cw.status) {
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	// A partial response describes a byte range of the unencoded body,
+	// which the compressed bytes would no longer match.
+	if cw.status == http.StatusPartialContent || h.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	for _, prefix := range alreadyCompressed {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// bodyAllowed reports whether a response with the given status may have
+// a body, and so a Content-Length.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Flush sends what has been written so far. A handler that flushes
+// before minSize bytes is streaming, so the response is sent
+// uncompressed rather than gzipping a few bytes at a time.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close sends anything still buffered and returns the encoder to its
+// pool.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// The whole body is buffered, so its length is known.
+		if bodyAllowed(cw.status) {
+			cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buf)))
+		}
+		cw.start(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+
+	cw.enc.Close()
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(enc)
+	case *zlib.Writer:
+		zlibPool.Put(enc)
+	}
+	cw.enc = nil
+}
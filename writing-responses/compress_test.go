@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveCompressed runs body through the compress middleware and returns the
+// recorded response.
+func serveCompressed(minSize int, acceptEncoding, contentType, body string) *httptest.ResponseRecorder {
+	h := compress(minSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decompress(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var r io.Reader = rec.Body
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello, world\n", 200)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		encoding       string
+	}{
+		{"below minSize", "gzip", "text/plain", "short", ""},
+		{"exactly minSize", "gzip", "text/plain", strings.Repeat("a", 1024), "gzip"},
+		{"one byte short", "gzip", "text/plain", strings.Repeat("a", 1023), ""},
+		{"large gzip", "gzip", "text/plain", large, "gzip"},
+		{"large deflate", "deflate", "text/plain", large, "deflate"},
+		{"gzip preferred on tie", "deflate, gzip", "text/plain", large, "gzip"},
+		{"weights respected", "gzip;q=0.5, deflate", "text/plain", large, "deflate"},
+		{"gzip refused", "gzip;q=0", "text/plain", large, ""},
+		{"both refused", "gzip;q=0, deflate;q=0", "text/plain", large, ""},
+		{"gzip refused, deflate accepted", "gzip;q=0, deflate", "text/plain", large, "deflate"},
+		{"no Accept-Encoding", "", "text/plain", large, ""},
+		{"unsupported encoding", "br", "text/plain", large, ""},
+		{"image skipped", "gzip", "image/png", large, ""},
+		{"zip skipped", "gzip", "application/zip", large, ""},
+		{"detected type", "gzip", "", large, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCompressed(1024, tt.acceptEncoding, tt.contentType, tt.body)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want [Accept-Encoding]", got)
+			}
+			if tt.encoding != "" && rec.Header().Get("Content-Length") != "" {
+				t.Error("compressed response must not keep Content-Length")
+			}
+			if got := decompress(t, rec); got != tt.body {
+				t.Errorf("body round-trip mismatch: got %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressSmallBodyHasContentLength(t *testing.T) {
+	rec := serveCompressed(1024, "gzip", "text/plain", "short")
+
+	if got := rec.Header().Get("Content-Length"); got != "5" {
+		t.Errorf("Content-Length = %q, want 5", got)
+	}
+}
+
+func TestCompressNoBodyStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusNoContent, http.StatusNotModified} {
+		h := compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != status {
+			t.Errorf("status = %d, want %d", rec.Code, status)
+		}
+		if _, ok := rec.Header()["Content-Length"]; ok {
+			t.Errorf("%d: Content-Length = %q, want none", status, rec.Header().Get("Content-Length"))
+		}
+	}
+}
+
+func TestCompressSkipsPartialContent(t *testing.T) {
+	content := strings.NewReader(strings.Repeat("x", 5000))
+	h := compress(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "x.txt", time.Time{}, content)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-1999")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if rec.Body.Len() != 2000 {
+		t.Errorf("body is %d bytes, want the 2000 in the range", rec.Body.Len())
+	}
+}
+
+func TestCompressEarlyFlushSendsUncompressed(t *testing.T) {
+	h := compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "tick\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, strings.Repeat("x", 2000))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length = %q, want none for a streamed body", got)
+	}
+	if want := "tick\n" + strings.Repeat("x", 2000); rec.Body.String() != want {
+		t.Errorf("body is %d bytes, want %d", rec.Body.Len(), len(want))
+	}
+}
+
+func TestCompressKeepsStatus(t *testing.T) {
+	h := compress(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("status %d, encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+}
+
+// BenchmarkCompress compares reusing gzip writers from gzipPool with
+// allocating a new one for every response.
+func BenchmarkCompress(b *testing.B) {
+	body := bytes.Repeat([]byte("hello, world\n"), 1000)
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			zw := gzipPool.Get().(*gzip.Writer)
+			zw.Reset(io.Discard)
+			zw.Write(body)
+			zw.Close()
+			gzipPool.Put(zw)
+		}
+	})
+
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			zw := gzip.NewWriter(io.Discard)
+			zw.Write(body)
+			zw.Close()
+		}
+	})
+
+	b.Run("middleware", func(b *testing.B) {
+		h := compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(body)
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		b.ReportAllocs()
+		for b.Loop() {
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type event struct {
+	ID   int
+	Data string
+}
+
+// eventLog keeps the most recent events in a fixed-size ring buffer so
+// reconnecting clients can catch up from their Last-Event-ID.
+type eventLog struct {
+	mu      sync.Mutex
+	events  []event
+	next    int
+	full    bool
+	lastID  int
+	changed chan struct{}
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		events:  make([]event, size),
+		changed: make(chan struct{}),
+	}
+}
+
+// publish adds an event and wakes every waiting subscriber.
+func (l *eventLog) publish(data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastID++
+	l.events[l.next] = event{ID: l.lastID, Data: data}
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns buffered events with an ID greater than id, oldest
+// first, and a channel that is closed when the next event is published.
+func (l *eventLog) since(id int) ([]event, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start, count := 0, l.next
+	if l.full {
+		start, count = l.next, len(l.events)
+	}
+
+	var events []event
+	for i := 0; i < count; i++ {
+		e := l.events[(start+i)%len(l.events)]
+		if e.ID > id {
+			events = append(events, e)
+		}
+	}
+	return events, l.changed
+}
+
+// latestID returns the ID of the most recently published event.
+func (l *eventLog) latestID() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastID
+}
+
+// serveEvents streams the log as Server-Sent Events until the client
+// disconnects, sending a comment every heartbeat to keep the connection
+// open through proxies.
+func serveEvents(events *eventLog, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		// Streams stay open far longer than any server write timeout.
+		err := rc.SetWriteDeadline(time.Time{})
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// New clients only see new events. Reconnecting clients send the
+		// last ID they saw and get everything after it that is buffered.
+		// An ID above the latest one comes from before a server restart,
+		// when IDs started again from 1, so it is ignored.
+		lastID := events.latestID()
+		if header := r.Header.Get("Last-Event-ID"); header != "" {
+			id, err := strconv.Atoi(header)
+			if err == nil && id >= 0 && id <= lastID {
+				lastID = id
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			batch, changed := events.since(lastID)
+			for _, e := range batch {
+				writeEvent(w, e)
+				lastID = e.ID
+			}
+			if len(batch) > 0 {
+				err = rc.Flush()
+				if err != nil {
+					return
+				}
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changed:
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				err = rc.Flush()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeEvent writes e in the text/event-stream format. Each line of the
+// data gets its own data: field.
+func writeEvent(w http.ResponseWriter, e event) {
+	fmt.Fprintf(w, "id: %d\n", e.ID)
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
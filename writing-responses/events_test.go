@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readIDs connects to url with the given Last-Event-ID and returns the
+// event IDs received before publishing stops for a moment.
+func readIDs(t *testing.T, url, lastEventID string, after func()) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	// The handler has flushed its headers, so it is subscribed.
+	after()
+
+	var ids []string
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return ids
+			}
+			if id, found := strings.CutPrefix(line, "id: "); found {
+				ids = append(ids, id)
+			}
+		case <-time.After(100 * time.Millisecond):
+			return ids
+		}
+	}
+}
+
+func TestServeEventsLastEventID(t *testing.T) {
+	events := newEventLog(10)
+	for range 3 {
+		events.publish("tick")
+	}
+
+	srv := httptest.NewServer(serveEvents(events, time.Hour))
+	defer srv.Close()
+
+	publish := func() { events.publish("tick") }
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        string
+	}{
+		{"new client", "", "4"},
+		{"resume", "2", "3,4,5"},
+		{"up to date", "5", "6"},
+		{"from before restart", "500", "7"},
+		{"negative", "-1", "8"},
+		{"not a number", "abc", "9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(readIDs(t, srv.URL, tt.lastEventID, publish), ",")
+			if got != tt.want {
+				t.Errorf("ids = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestServeEventsWithoutDeadlines uses a ResponseRecorder, which cannot
+// set write deadlines, to check that streaming still works.
+func TestServeEventsWithoutDeadlines(t *testing.T) {
+	events := newEventLog(10)
+	events.publish("first")
+	events.publish("second")
+
+	// A cancelled request makes the handler send what is buffered and
+	// return instead of waiting for more.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "0")
+
+	rec := httptest.NewRecorder()
+	serveEvents(events, time.Hour).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	want := "id: 1\ndata: first\n\nid: 2\ndata: second\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if !rec.Flushed {
+		t.Error("events were not flushed")
+	}
+}
+
+func TestServeEventsNotCompressed(t *testing.T) {
+	events := newEventLog(10)
+	srv := httptest.NewServer(compress(1024)(serveEvents(events, time.Hour)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Setting the header ourselves stops the transport from quietly
+	// decompressing the body.
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none", got)
+	}
+
+	events.publish("tick")
+	sc := bufio.NewScanner(resp.Body)
+	if !sc.Scan() || sc.Text() != "id: 1" {
+		t.Errorf("first line = %q, want %q", sc.Text(), "id: 1")
+	}
+}
+
+func TestEventLogSinceWraps(t *testing.T) {
+	events := newEventLog(3)
+	for range 5 {
+		events.publish("tick")
+	}
+
+	batch, _ := events.since(0)
+	if len(batch) != 3 || batch[0].ID != 3 || batch[2].ID != 5 {
+		t.Errorf("since(0) = %+v, want IDs 3 to 5", batch)
+	}
+}
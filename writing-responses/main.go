@@ -1,6 +1,10 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+	"time"
+)
 
 func main() {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -8,5 +12,18 @@ func main() {
 		w.Write([]byte("from Go"))
 	})
 
-	http.ListenAndServe(":8080", nil)
+	http.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(strings.Repeat("Hello from Go\n", 500)))
+	})
+
+	events := newEventLog(100)
+	go func() {
+		for t := range time.Tick(2 * time.Second) {
+			events.publish("Server time: " + t.Format(time.TimeOnly))
+		}
+	}()
+	http.HandleFunc("/events", serveEvents(events, 15*time.Second))
+
+	http.ListenAndServe(":8080", compress(1024)(http.DefaultServeMux))
 }